@@ -0,0 +1,241 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createAccountRequest 创建账户请求
+type createAccountRequest struct {
+	UserID  int64 `json:"user_id" binding:"required"`
+	Balance int64 `json:"balance"` // 初始余额，单位：分，默认 0
+}
+
+// createAccountHandler 创建账户
+// user_id 已存在返回 409
+func createAccountHandler(c *gin.Context) {
+	var req createAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserID <= 0 || req.Balance < 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request",
+		})
+		return
+	}
+
+	account, err := accountService.CreateAccount(c.Request.Context(), req.UserID, req.Balance)
+	if errors.Is(err, service.ErrAccountExists) {
+		c.JSON(http.StatusConflict, Response{
+			Code:    409,
+			Message: "account already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Code:    201,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// listAccountsHandler 分页查询账户
+// 查询参数：?cursor=<上一页的 next_cursor>&limit=<每页条数>&min_balance=&max_balance=&status=active|closed，
+// 按 user_id 升序返回，余额单位为分
+func listAccountsHandler(c *gin.Context) {
+	var query service.AccountQuery
+	var err error
+
+	if s := c.Query("cursor"); s != "" {
+		query.Cursor, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || query.Cursor < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid cursor",
+			})
+			return
+		}
+	}
+
+	if s := c.Query("limit"); s != "" {
+		query.Limit, err = strconv.Atoi(s)
+		if err != nil || query.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid limit",
+			})
+			return
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **int64
+	}{
+		{"min_balance", &query.MinBalance},
+		{"max_balance", &query.MaxBalance},
+	} {
+		s := c.Query(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid " + p.name,
+			})
+			return
+		}
+		*p.dst = &v
+	}
+
+	query.Status = c.Query("status")
+	if query.Status != "" && query.Status != model.AccountStatusActive && query.Status != model.AccountStatusClosed {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid status",
+		})
+		return
+	}
+
+	page, err := accountService.FindAccounts(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    page,
+	})
+}
+
+// getAccountHandler 查询账户详情
+func getAccountHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	account, err := accountService.GetAccount(userID)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// closeAccountHandler 关闭账户
+// 账户记录和流水保留，状态改为 closed，之后的扣款和重置返回 409
+func closeAccountHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	account, err := accountService.CloseAccount(c.Request.Context(), userID)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// updateAccountPolicyHandler 整体替换账户扣款策略，请求体中省略的字段视为 0（不限制）
+// 参数不合法返回 400，账户不存在返回 404，已关闭返回 409
+func updateAccountPolicyHandler(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var policy model.AccountPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	account, err := accountService.UpdateAccountPolicy(c.Request.Context(), userID, policy)
+	if errors.Is(err, service.ErrInvalidPolicy) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    account,
+	})
+}
+
+// parseUserIDParam 解析路径参数 user_id，不合法时直接返回 400
+func parseUserIDParam(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid user_id",
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+// respondAccountError 账户不存在返回 404，已关闭返回 409，其他错误返回 500
+func respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Message: "account not found",
+		})
+	case errors.Is(err, service.ErrAccountClosed):
+		c.JSON(http.StatusConflict, Response{
+			Code:    409,
+			Message: "account closed",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+	}
+}
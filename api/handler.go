@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,10 +18,15 @@ import (
 )
 
 var (
-	accountService = service.NewAccountService()
-
-	// WebSocket 连接管理
-	wsClients  = make(map[*websocket.Conn]bool)
+	// accountService 账户服务，由 RegisterRoutes 注入
+	accountService *service.AccountService
+	// reconciler 对账任务，由 RegisterRoutes 注入
+	reconciler *service.Reconciler
+	// idempotency 幂等键处理，由 RegisterRoutes 注入
+	idempotency *service.IdempotencyService
+
+	// WebSocket 连接管理，value 为该连接订阅的账户
+	wsClients  = make(map[*websocket.Conn]*wsSubscription)
 	wsMutex    sync.Mutex
 	wsUpgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
@@ -27,12 +35,7 @@ var (
 	}
 
 	// 统计数据
-	stats = &Stats{
-		TotalRequests: 0,
-		SuccessCount:  0,
-		FailureCount:  0,
-		StartTime:     time.Now(),
-	}
+	stats      = newStats()
 	statsMutex sync.Mutex
 
 	// 监控状态控制
@@ -42,18 +45,17 @@ var (
 	monitoringMutex    sync.RWMutex // 使用读写锁，读多写少的场景
 
 	// 历史数据存储
-	// balanceHistory 存储余额的历史数据点，用于历史查看功能
-	// 最多保存最近1000条记录，超过则删除最早的记录
-	balanceHistory      []BalanceHistory
+	// balanceHistory 按账户存储余额的历史数据点，用于历史查看功能
+	// 每个账户最多保存最近1000条记录，超过则删除最早的记录
+	balanceHistory      = make(map[int64][]BalanceHistory)
 	balanceHistoryMutex sync.RWMutex
-	maxHistorySize      = 1000 // 最大历史记录数
+	maxHistorySize      = 1000 // 每个账户的最大历史记录数
 
 	// 执行模式控制
-	// useLockMode 表示是否使用加锁模式
-	// true: 使用互斥锁保护，无并发问题但性能略低
-	// false: 不加锁，演示并发问题
-	useLockMode bool
-	modeMutex   sync.RWMutex
+	// currentStrategy 当前使用的扣款策略名称，可选值见 accountService.Strategies()
+	// 默认不加锁，演示并发问题
+	currentStrategy = service.DefaultStrategy
+	modeMutex       sync.RWMutex
 )
 
 // Stats 统计信息
@@ -62,14 +64,47 @@ type Stats struct {
 	SuccessCount  int64     `json:"success_count"`
 	FailureCount  int64     `json:"failure_count"`
 	StartTime     time.Time `json:"start_time"`
+
+	// RetryCount 重试类策略（乐观锁、SERIALIZABLE）累计重试次数
+	RetryCount int64 `json:"retry_count"`
+	// ErrorClasses 失败请求按最终错误分类计数，如 deadlock、insufficient_balance
+	ErrorClasses map[string]int64 `json:"error_classes"`
+
+	// LockWait 加锁模式下活跃账户的锁等待统计，已释放的账户合并为 key=-1 的汇总项，仅 /api/stats 返回
+	LockWait []service.KeyLockStats `json:"lock_wait,omitempty"`
+	// ActorQueues 单写者模式下每个账户的队列深度，仅 /api/stats 返回
+	ActorQueues []service.ActorQueueStats `json:"actor_queues,omitempty"`
+}
+
+// newStats 创建一份清零的统计数据
+func newStats() *Stats {
+	return &Stats{
+		StartTime:    time.Now(),
+		ErrorClasses: make(map[string]int64),
+	}
+}
+
+// snapshotStats 复制当前统计数据，避免在锁外读取共享的 map
+func snapshotStats() Stats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	snapshot := *stats
+	snapshot.ErrorClasses = make(map[string]int64, len(stats.ErrorClasses))
+	for class, count := range stats.ErrorClasses {
+		snapshot.ErrorClasses[class] = count
+	}
+	return snapshot
 }
 
 // BalanceHistory 余额历史数据点
 // 用于记录每个时间点的实际余额和理论余额，支持历史查看功能
 type BalanceHistory struct {
+	UserID          int64 `json:"user_id"`          // 用户ID
 	Timestamp       int64 `json:"timestamp"`        // 时间戳（毫秒）
 	ActualBalance   int64 `json:"actual_balance"`   // 实际余额（分）
 	ExpectedBalance int64 `json:"expected_balance"` // 理论余额（分）
+	LostAmount      int64 `json:"lost_amount"`      // 实际余额 - 理论余额（分）
 }
 
 // Response 统一响应格式
@@ -88,12 +123,14 @@ type WSMessage struct {
 
 // TraceEvent 时序追踪事件
 type TraceEvent struct {
+	UserID     int64  `json:"user_id"`
 	RequestID  string `json:"request_id"`
 	Step       int    `json:"step"`
 	StepName   string `json:"step_name"`
 	Balance    int64  `json:"balance"`
 	Amount     int64  `json:"amount"`
 	NewBalance int64  `json:"new_balance,omitempty"`
+	Credit     bool   `json:"credit,omitempty"` // 是否为入账
 	Timestamp  int64  `json:"timestamp"`
 }
 
@@ -120,15 +157,16 @@ type ConflictSnapshot struct {
 	RequestB_WriteValue int64  `json:"request_b_write_value"` // 可能覆盖A的写入
 
 	// 数据库视角
-	DB_InitialValue int64 `json:"db_initial_value"` // 初始值
-	DB_AfterA       int64 `json:"db_after_a"`       // A写入后的值
-	DB_AfterB       int64 `json:"db_after_b"`       // B写入后的值
-	DB_ExpectedB    int64 `json:"db_expected_b"`    // 如果B基于A的结果计算，应该得到的值
-	IsConflict      bool  `json:"is_conflict"`      // 是否发生冲突（B读到了旧值）
-	LostAmount      int64 `json:"lost_amount"`      // 丢失的金额
-	UseLock         bool  `json:"use_lock"`         // 是否使用了锁
-	CapturedAt      int64 `json:"captured_at"`      // 快照捕获时间
-	Amount          int64 `json:"amount"`           // 每次扣款金额
+	DB_InitialValue int64  `json:"db_initial_value"` // 初始值
+	DB_AfterA       int64  `json:"db_after_a"`       // A写入后的值
+	DB_AfterB       int64  `json:"db_after_b"`       // B写入后的值
+	DB_ExpectedB    int64  `json:"db_expected_b"`    // 如果B基于A的结果计算，应该得到的值
+	IsConflict      bool   `json:"is_conflict"`      // 是否发生冲突（B读到了旧值）
+	LostAmount      int64  `json:"lost_amount"`      // 丢失的金额
+	UseLock         bool   `json:"use_lock"`         // 是否使用了锁
+	Strategy        string `json:"strategy"`         // 捕获时使用的扣款策略
+	CapturedAt      int64  `json:"captured_at"`      // 快照捕获时间
+	Amount          int64  `json:"amount"`           // 每次扣款金额
 }
 
 var (
@@ -156,7 +194,11 @@ type RequestTrace struct {
 
 // RegisterRoutes 注册路由
 // 注册所有HTTP路由和WebSocket端点
-func RegisterRoutes(r *gin.Engine) {
+func RegisterRoutes(r *gin.Engine, svc *service.AccountService, rec *service.Reconciler, idem *service.IdempotencyService) {
+	accountService = svc
+	reconciler = rec
+	idempotency = idem
+
 	// 加载HTML模板
 	r.LoadHTMLGlob("./web/*.html")
 
@@ -173,8 +215,24 @@ func RegisterRoutes(r *gin.Engine) {
 	// API 路由组
 	api := r.Group("/api")
 	{
-		// 余额扣减接口
-		api.POST("/deduct", deductHandler)
+		// 余额扣减接口，支持 Idempotency-Key 请求头
+		api.POST("/deduct", idempotencyMiddleware, deductHandler)
+
+		// 入账接口，与扣款使用同一个并发控制策略，同样支持 Idempotency-Key
+		api.POST("/credit", idempotencyMiddleware, creditHandler)
+
+		// 转账接口，两个账户按 user_id 顺序加锁，同样支持 Idempotency-Key
+		api.POST("/transfer", idempotencyMiddleware, transferHandler)
+
+		// 余额总和守恒检查
+		api.GET("/invariant", getInvariantHandler)
+
+		// 预授权冻结接口，冻结和扣款使用当前扣款策略（或请求体中的 strategy）
+		api.POST("/holds", idempotencyMiddleware, createHoldHandler)              // 冻结金额
+		api.GET("/holds", listHoldsHandler)                                       // 分页查询账户的冻结记录
+		api.GET("/holds/:id", getHoldHandler)                                     // 查询冻结记录
+		api.POST("/holds/:id/capture", idempotencyMiddleware, captureHoldHandler) // 对冻结扣款
+		api.POST("/holds/:id/void", idempotencyMiddleware, voidHoldHandler)       // 撤销冻结
 
 		// 余额查询接口
 		api.GET("/balance/:user_id", getBalanceHandler)
@@ -182,6 +240,20 @@ func RegisterRoutes(r *gin.Engine) {
 		// 重置余额接口
 		api.POST("/reset", resetBalanceHandler)
 
+		// 账户接口
+		api.POST("/accounts", createAccountHandler)                      // 创建账户
+		api.GET("/accounts", listAccountsHandler)                        // 分页查询账户，可按余额范围和状态过滤
+		api.GET("/accounts/:user_id", getAccountHandler)                 // 查询账户详情
+		api.DELETE("/accounts/:user_id", closeAccountHandler)            // 关闭账户
+		api.PUT("/accounts/:user_id/policy", updateAccountPolicyHandler) // 修改账户扣款策略
+
+		// 账户流水接口
+		api.GET("/accounts/:user_id/transactions", listTransactionsHandler)
+
+		// 对账接口
+		api.POST("/reconcile", runReconcileHandler)                // 立即执行一次对账
+		api.GET("/reconcile/reports", listReconcileReportsHandler) // 查询对账差异报告
+
 		// 统计信息接口
 		api.GET("/stats", getStatsHandler)
 
@@ -210,10 +282,27 @@ func RegisterRoutes(r *gin.Engine) {
 
 	// WebSocket
 	r.GET("/ws", wsHandler)
+
+	// 单写者模式的队列深度变化实时推送给前端
+	accountService.OnActorQueueChange(broadcastActorQueue)
+
+	// 对账结果实时推送给前端
+	reconciler.OnRun(broadcastReconcile)
 }
 
 // deductHandler 余额扣减接口
 func deductHandler(c *gin.Context) {
+	balanceChangeHandler(c, false)
+}
+
+// creditHandler 入账接口，与扣款使用同一个并发控制策略
+func creditHandler(c *gin.Context) {
+	balanceChangeHandler(c, true)
+}
+
+// balanceChangeHandler 扣款和入账共用的处理流程
+// 读取余额 -> 按当前策略执行 -> 广播追踪事件并记录冲突，两者在追踪和冲突检测中的处理完全相同
+func balanceChangeHandler(c *gin.Context, credit bool) {
 	var req service.DeductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
@@ -222,6 +311,20 @@ func deductHandler(c *gin.Context) {
 		})
 		return
 	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: amount must be positive",
+		})
+		return
+	}
+
+	opName := "扣款"
+	apply := accountService.Deduct
+	if credit {
+		opName = "入账"
+		apply = accountService.Credit
+	}
 
 	// 生成请求ID
 	requestID := uuid.New().String()[:8]
@@ -238,68 +341,122 @@ func deductHandler(c *gin.Context) {
 		stats.FailureCount++
 		statsMutex.Unlock()
 
-		c.JSON(http.StatusNotFound, Response{
-			Code:    404,
-			Message: "account not found",
-		})
+		respondAccountError(c, err)
+		return
+	}
+
+	// 已关闭的账户拒绝扣款和入账
+	if account.Closed() {
+		statsMutex.Lock()
+		stats.FailureCount++
+		statsMutex.Unlock()
+
+		respondAccountError(c, service.ErrAccountClosed)
 		return
 	}
 
 	broadcastTrace(TraceEvent{
+		UserID:    req.UserID,
 		RequestID: requestID,
 		Step:      1,
 		StepName:  "读取余额",
 		Balance:   account.Balance,
 		Amount:    req.Amount,
+		Credit:    credit,
 		Timestamp: time.Now().UnixMilli(),
 	})
 
-	// Step 2: 执行扣款（根据当前模式选择实现）
-	modeMutex.RLock()
-	currentMode := useLockMode
-	modeMutex.RUnlock()
+	// Step 2: 执行扣款或入账（根据当前策略选择实现）
+	strategy, ok := accountService.Strategies().Get(getCurrentStrategy())
+	if !ok {
+		statsMutex.Lock()
+		stats.FailureCount++
+		statsMutex.Unlock()
 
-	var resp *service.DeductResponse
-	if currentMode {
-		// 加锁模式：使用互斥锁保护
-		resp, err = accountService.DeductBalanceWithLock(&req, requestID)
-	} else {
-		// 无锁模式：演示并发问题
-		resp, err = accountService.DeductBalance(&req, requestID)
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: "current strategy not registered",
+		})
+		return
 	}
 
+	resp, err := apply(c.Request.Context(), strategy, &req, requestID)
 	if err != nil {
+		errorClass := service.ClassifyError(err)
+		retries := 0
+		var retryErr *service.RetryError
+		if errors.As(err, &retryErr) {
+			errorClass = retryErr.Class
+			retries = retryErr.Retries
+		}
+
 		statsMutex.Lock()
 		stats.FailureCount++
+		stats.RetryCount += int64(retries)
+		stats.ErrorClasses[errorClass]++
 		statsMutex.Unlock()
 
 		broadcastTrace(TraceEvent{
+			UserID:    req.UserID,
 			RequestID: requestID,
 			Step:      2,
-			StepName:  "扣款失败",
+			StepName:  opName + "失败",
 			Balance:   account.Balance,
 			Amount:    req.Amount,
+			Credit:    credit,
 			Timestamp: time.Now().UnixMilli(),
 		})
 
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
+		data := map[string]interface{}{
+			"request_id":  requestID,
+			"retries":     retries,
+			"error_class": errorClass,
+		}
+		var policyErr *service.PolicyError
+		if errors.As(err, &policyErr) {
+			data["policy_violation"] = policyErr
+		}
+
+		status := deductErrorStatus(err)
+		c.JSON(status, Response{
+			Code:    status,
 			Message: err.Error(),
+			Data:    data,
 		})
 		return
 	}
 
 	statsMutex.Lock()
 	stats.SuccessCount++
+	stats.RetryCount += int64(resp.Retries)
 	statsMutex.Unlock()
 
+	// 重试类策略：逐个广播失败的尝试，前端可以看到 CAS 冲突
+	for _, attempt := range resp.Timeline.Attempts {
+		if attempt.Success {
+			continue
+		}
+		broadcastTrace(TraceEvent{
+			UserID:    req.UserID,
+			RequestID: requestID,
+			Step:      2,
+			StepName:  fmt.Sprintf("CAS冲突重试(第%d次)", attempt.Attempt),
+			Balance:   attempt.ReadBalance,
+			Amount:    req.Amount,
+			Credit:    credit,
+			Timestamp: attempt.WriteEnd / int64(time.Millisecond),
+		})
+	}
+
 	// Step 3: 写入完成
 	broadcastTrace(TraceEvent{
+		UserID:     req.UserID,
 		RequestID:  requestID,
 		Step:       3,
 		StepName:   "写入完成",
 		Balance:    resp.OldBalance,
 		Amount:     req.Amount,
+		Credit:     credit,
 		NewBalance: resp.Balance,
 		Timestamp:  time.Now().UnixMilli(),
 	})
@@ -314,18 +471,68 @@ func deductHandler(c *gin.Context) {
 	})
 }
 
+// deductErrorStatus 将扣款错误映射为 HTTP 状态码
+// 锁竞争类错误返回 409，便于客户端区分"抢锁失败可重试"和"余额不足"；
+// 业务拒绝返回 4xx，幂等键会把它当作最终结果保存。请求被取消或超时、存储出错时结果不确定，
+// 返回 5xx 让幂等键被释放，不能把一次可能已经生效的扣款缓存成 400
+func deductErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrLockTimeout),
+		errors.Is(err, service.ErrLockLost),
+		errors.Is(err, service.ErrDeadlock),
+		errors.Is(err, service.ErrSerializationFailure),
+		errors.Is(err, service.ErrOptimisticConflict):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrQueueFull):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrRedisUnavailable),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrAccountNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAccountClosed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInsufficientBalance),
+		errors.Is(err, service.ErrPolicyViolation),
+		errors.Is(err, service.ErrInvalidAmount),
+		errors.Is(err, service.ErrSameAccount),
+		errors.Is(err, service.ErrTransferUnsupported),
+		errors.Is(err, service.ErrHoldUnsupported),
+		errors.Is(err, service.ErrCaptureExceedsHold),
+		errors.Is(err, service.ErrInvalidHoldTTL):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // getBalanceHandler 获取余额
 func getBalanceHandler(c *gin.Context) {
-	userID := int64(1) // 默认用户ID
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid user_id",
+		})
+		return
+	}
 
 	balance, err := accountService.GetBalance(userID)
-	if err != nil {
+	if errors.Is(err, service.ErrAccountNotFound) {
 		c.JSON(http.StatusNotFound, Response{
 			Code:    404,
 			Message: "account not found",
 		})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, Response{
 		Code:    200,
@@ -353,25 +560,18 @@ func resetBalanceHandler(c *gin.Context) {
 	}
 
 	if err := accountService.ResetBalance(req.UserID, req.Balance); err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    500,
-			Message: err.Error(),
-		})
+		respondAccountError(c, err)
 		return
 	}
 
 	// 重置统计
 	statsMutex.Lock()
-	stats = &Stats{
-		TotalRequests: 0,
-		SuccessCount:  0,
-		FailureCount:  0,
-		StartTime:     time.Now(),
-	}
+	stats = newStats()
 	statsMutex.Unlock()
+	accountService.ResetLockWaitStats()
 
-	// 广播重置事件
-	broadcast(WSMessage{
+	// 向订阅该账户的客户端广播重置事件
+	broadcastToSubscribers(req.UserID, WSMessage{
 		Type: "reset",
 		Data: map[string]interface{}{
 			"user_id": req.UserID,
@@ -387,15 +587,16 @@ func resetBalanceHandler(c *gin.Context) {
 }
 
 // getStatsHandler 获取统计信息
-// 返回当前的请求统计数据，包括总请求数、成功数、失败数
+// 返回当前的请求统计数据，包括总请求数、成功数、失败数，以及每个账户的锁等待统计
 func getStatsHandler(c *gin.Context) {
-	statsMutex.Lock()
-	defer statsMutex.Unlock()
+	snapshot := snapshotStats()
+	snapshot.LockWait = accountService.LockWaitStats()
+	snapshot.ActorQueues = accountService.ActorQueueStats()
 
 	c.JSON(http.StatusOK, Response{
 		Code:    200,
 		Message: "success",
-		Data:    stats,
+		Data:    snapshot,
 	})
 }
 
@@ -480,11 +681,13 @@ func resumeMonitoringHandler(c *gin.Context) {
 	})
 }
 
-// switchModeHandler 切换执行模式（加锁/不加锁）
-// 请求体: {"use_lock": true/false}
+// switchModeHandler 切换执行模式（扣款策略）
+// 请求体: {"strategy": "mutex"}
+// 兼容旧版请求体 {"use_lock": true/false}，分别映射到 mutex / unlocked 策略
 func switchModeHandler(c *gin.Context) {
 	var req struct {
-		UseLock bool `json:"use_lock"`
+		Strategy string `json:"strategy"`
+		UseLock  *bool  `json:"use_lock"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -495,74 +698,108 @@ func switchModeHandler(c *gin.Context) {
 		return
 	}
 
+	name := req.Strategy
+	if name == "" && req.UseLock != nil {
+		name = service.StrategyUnlocked
+		if *req.UseLock {
+			name = service.StrategyMutex
+		}
+	}
+
+	strategy, ok := accountService.Strategies().Get(name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: fmt.Sprintf("unknown strategy: %q", name),
+			Data: map[string]interface{}{
+				"strategies": accountService.Strategies().List(),
+			},
+		})
+		return
+	}
+
 	modeMutex.Lock()
-	useLockMode = req.UseLock
+	currentStrategy = strategy.Name()
 	modeMutex.Unlock()
 
-	mode := "unlocked"
-	if req.UseLock {
-		mode = "locked"
-	}
+	log.Printf("执行模式已切换: %s", strategy.Name())
 
-	log.Printf("执行模式已切换: %s", mode)
+	data := map[string]interface{}{
+		"mode":        strategy.Name(),
+		"strategy":    strategy.Name(),
+		"description": strategy.Description(),
+		"use_lock":    strategy.Name() != service.StrategyUnlocked,
+	}
 
 	// 广播模式变更
 	broadcast(WSMessage{
-		Type: "mode_changed",
-		Data: map[string]interface{}{
-			"mode":     mode,
-			"use_lock": req.UseLock,
-		},
+		Type:      "mode_changed",
+		Data:      data,
 		Timestamp: time.Now().UnixMilli(),
 	})
 
 	c.JSON(http.StatusOK, Response{
 		Code:    200,
 		Message: "mode switched successfully",
-		Data: map[string]interface{}{
-			"mode":     mode,
-			"use_lock": req.UseLock,
-		},
+		Data:    data,
 	})
 }
 
-// getModeStatusHandler 获取当前执行模式
+// getModeStatusHandler 获取当前执行模式及所有已注册的策略
 func getModeStatusHandler(c *gin.Context) {
-	modeMutex.RLock()
-	defer modeMutex.RUnlock()
-
-	mode := "unlocked"
-	if useLockMode {
-		mode = "locked"
-	}
+	name := getCurrentStrategy()
 
 	c.JSON(http.StatusOK, Response{
 		Code:    200,
 		Message: "success",
 		Data: map[string]interface{}{
-			"mode":     mode,
-			"use_lock": useLockMode,
+			"mode":       name,
+			"strategy":   name,
+			"use_lock":   name != service.StrategyUnlocked,
+			"strategies": accountService.Strategies().List(),
 		},
 	})
 }
 
+// getCurrentStrategy 获取当前扣款策略名称
+func getCurrentStrategy() string {
+	modeMutex.RLock()
+	defer modeMutex.RUnlock()
+	return currentStrategy
+}
+
 // getBalanceHistoryHandler 获取历史余额数据
-// 支持通过查询参数指定时间范围：?start=timestamp&end=timestamp
-// 如果不指定参数，返回所有历史数据
+// 支持通过查询参数指定账户和时间范围：?user_id=1&start=timestamp&end=timestamp
+// 不指定 user_id 时为账户 1；不指定时间范围时返回该账户的所有历史数据
 func getBalanceHistoryHandler(c *gin.Context) {
 	// 获取查询参数
 	startStr := c.Query("start")
 	endStr := c.Query("end")
 
+	userID := int64(defaultSubscribedUserID)
+	if s := c.Query("user_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid user_id",
+			})
+			return
+		}
+		userID = id
+	}
+
 	balanceHistoryMutex.RLock()
 	defer balanceHistoryMutex.RUnlock()
 
+	history := balanceHistory[userID]
+
 	// 如果没有指定时间范围，返回所有数据
 	if startStr == "" && endStr == "" {
 		c.JSON(http.StatusOK, Response{
 			Code:    200,
 			Message: "success",
-			Data:    balanceHistory,
+			Data:    history,
 		})
 		return
 	}
@@ -595,7 +832,7 @@ func getBalanceHistoryHandler(c *gin.Context) {
 
 	// 过滤时间范围内的数据
 	var filteredData []BalanceHistory
-	for _, item := range balanceHistory {
+	for _, item := range history {
 		// 如果只指定了开始时间
 		if startStr != "" && endStr == "" {
 			if item.Timestamp >= startTime {
@@ -629,27 +866,30 @@ func parseTimestamp(s string) (int64, error) {
 	return timestamp, err
 }
 
-// addBalanceHistory 添加余额历史记录
-// 当历史记录超过最大限制时，删除最早的记录
-func addBalanceHistory(actualBalance, expectedBalance int64) {
+// addBalanceHistory 添加账户的余额历史记录
+// 当该账户的历史记录超过最大限制时，删除最早的记录
+func addBalanceHistory(userID, actualBalance, expectedBalance int64) {
 	balanceHistoryMutex.Lock()
 	defer balanceHistoryMutex.Unlock()
 
 	// 创建新的历史记录
 	history := BalanceHistory{
+		UserID:          userID,
 		Timestamp:       time.Now().UnixMilli(),
 		ActualBalance:   actualBalance,
 		ExpectedBalance: expectedBalance,
+		LostAmount:      actualBalance - expectedBalance,
 	}
 
 	// 添加到切片
-	balanceHistory = append(balanceHistory, history)
+	items := append(balanceHistory[userID], history)
 
 	// 如果超过最大限制，删除最早的记录
-	if len(balanceHistory) > maxHistorySize {
+	if len(items) > maxHistorySize {
 		// 使用切片操作删除第一个元素
-		balanceHistory = balanceHistory[1:]
+		items = items[1:]
 	}
+	balanceHistory[userID] = items
 }
 
 // getMonitoringStatusHandler 获取监控状态
@@ -674,7 +914,17 @@ func getMonitoringStatusHandler(c *gin.Context) {
 
 // wsHandler WebSocket处理
 // 处理WebSocket连接，用于实时推送数据到前端
+// 连接时可通过 ?user_id=1,2 指定订阅的账户（默认账户 1），之后可发送 subscribe/unsubscribe 消息调整
 func wsHandler(c *gin.Context) {
+	userIDs, err := parseUserIDs(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid user_id",
+		})
+		return
+	}
+
 	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -682,36 +932,31 @@ func wsHandler(c *gin.Context) {
 	}
 
 	wsMutex.Lock()
-	wsClients[conn] = true
+	wsClients[conn] = newWSSubscription(userIDs)
+	total := len(wsClients)
 	wsMutex.Unlock()
 
-	log.Printf("WebSocket client connected, total clients: %d", len(wsClients))
+	log.Printf("WebSocket client connected, subscribed=%v, total clients: %d", userIDs, total)
 
-	// 发送初始状态
-	balance, _ := accountService.GetBalance(1)
-	conn.WriteJSON(WSMessage{
-		Type: "init",
-		Data: map[string]interface{}{
-			"balance": balance,
-			"stats":   stats,
-		},
-		Timestamp: time.Now().UnixMilli(),
-	})
+	// 发送订阅账户的初始状态
+	sendInitialState(conn, userIDs)
 
 	// 保持连接
 	defer func() {
 		wsMutex.Lock()
 		delete(wsClients, conn)
+		remaining := len(wsClients)
 		wsMutex.Unlock()
 		conn.Close()
-		log.Printf("WebSocket client disconnected, remaining clients: %d", len(wsClients))
+		log.Printf("WebSocket client disconnected, remaining clients: %d", remaining)
 	}()
 
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		handleWSClientMessage(conn, data)
 	}
 }
 
@@ -721,24 +966,49 @@ func broadcast(msg WSMessage) {
 	defer wsMutex.Unlock()
 
 	for client := range wsClients {
-		err := client.WriteJSON(msg)
-		if err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			client.Close()
-			delete(wsClients, client)
+		writeWSMessage(client, msg)
+	}
+}
+
+// broadcastToSubscribers 只向订阅了 userID 的客户端发送消息
+func broadcastToSubscribers(userID int64, msg WSMessage) {
+	wsMutex.Lock()
+	defer wsMutex.Unlock()
+
+	for client, sub := range wsClients {
+		if sub.has(userID) {
+			writeWSMessage(client, msg)
 		}
 	}
 }
 
-// broadcastTrace 广播追踪事件
+// writeWSMessage 向单个客户端写消息，失败时关闭并移除连接；调用方需持有 wsMutex
+func writeWSMessage(client *websocket.Conn, msg WSMessage) {
+	if err := client.WriteJSON(msg); err != nil {
+		log.Printf("WebSocket write error: %v", err)
+		client.Close()
+		delete(wsClients, client)
+	}
+}
+
+// broadcastTrace 向订阅了该账户的客户端广播追踪事件
 func broadcastTrace(event TraceEvent) {
-	broadcast(WSMessage{
+	broadcastToSubscribers(event.UserID, WSMessage{
 		Type:      "trace",
 		Data:      event,
 		Timestamp: time.Now().UnixMilli(),
 	})
 }
 
+// broadcastActorQueue 广播单写者模式下某个账户的队列深度
+func broadcastActorQueue(queue service.ActorQueueStats) {
+	broadcast(WSMessage{
+		Type:      "actor_queue",
+		Data:      queue,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
 // monitoringStopChan 用于通知后台监控任务停止
 // monitoringDone 用于等待后台任务真正退出
 var (
@@ -747,7 +1017,7 @@ var (
 )
 
 // StartBackgroundMonitoring 启动后台监控任务
-// 每500ms查询余额并广播，可通过 StopBackgroundMonitoring 停止
+// 每500ms查询客户端订阅的账户余额并推送给订阅者，每2s检查一次余额总和守恒，可通过 StopBackgroundMonitoring 停止
 func StartBackgroundMonitoring() {
 	go func() {
 		defer close(monitoringDone) // 退出时通知外部：任务已结束
@@ -757,6 +1027,9 @@ func StartBackgroundMonitoring() {
 
 		log.Println("后台余额监控任务已启动（500ms间隔）")
 
+		var tick int
+		var lastDrift int64
+
 		for {
 			select {
 			case <-monitoringStopChan:
@@ -773,32 +1046,65 @@ func StartBackgroundMonitoring() {
 					continue
 				}
 
-				balance, err := accountService.GetBalance(1)
-				if err != nil {
-					log.Printf("查询余额失败: %v", err)
-					continue
+				// 每隔几个周期检查一次全部账户的余额总和
+				tick++
+				if tick%invariantCheckInterval == 0 {
+					checkBalanceInvariant(&lastDrift)
 				}
 
-				statsMutex.Lock()
-				currentStats := *stats
-				statsMutex.Unlock()
-
-				expectedBalance := balance
-				addBalanceHistory(balance, expectedBalance)
-
-				broadcast(WSMessage{
-					Type: "balance_update",
-					Data: map[string]interface{}{
-						"balance": balance,
-						"stats":   currentStats,
-					},
-					Timestamp: time.Now().UnixMilli(),
-				})
+				// 只查询有客户端订阅的账户
+				currentStats := snapshotStats()
+				for _, userID := range subscribedUserIDs() {
+					drift, err := accountService.BalanceDrift(context.Background(), userID)
+					if err != nil {
+						log.Printf("查询余额失败: user_id=%d, %v", userID, err)
+						continue
+					}
+
+					addBalanceHistory(userID, drift.ActualBalance, drift.ExpectedBalance)
+
+					broadcastToSubscribers(userID, WSMessage{
+						Type: "balance_update",
+						Data: map[string]interface{}{
+							"user_id":          userID,
+							"balance":          drift.ActualBalance,
+							"expected_balance": drift.ExpectedBalance,
+							"lost_amount":      drift.LostAmount,
+							"stats":            currentStats,
+						},
+						Timestamp: time.Now().UnixMilli(),
+					})
+				}
 			}
 		}
 	}()
 }
 
+// invariantCheckInterval 每隔多少个监控周期检查一次余额总和守恒（4 × 500ms = 2s）
+// 检查需要读取全部账户，比单个账户的余额推送开销大
+const invariantCheckInterval = 4
+
+// checkBalanceInvariant 检查余额总和守恒并广播给所有客户端，偏差变化时记录日志
+func checkBalanceInvariant(lastDrift *int64) {
+	inv, err := accountService.CheckBalanceInvariant(context.Background())
+	if err != nil {
+		log.Printf("检查余额总和失败: %v", err)
+		return
+	}
+
+	if inv.Drift != *lastDrift {
+		log.Printf("⚠️ 余额总和偏差变化: %d -> %d 分（实际总额=%d，理论总额=%d，账户数=%d）",
+			*lastDrift, inv.Drift, inv.TotalBalance, inv.ExpectedTotal, inv.AccountCount)
+		*lastDrift = inv.Drift
+	}
+
+	broadcast(WSMessage{
+		Type:      "invariant",
+		Data:      inv,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
+
 // StopBackgroundMonitoring 停止后台监控任务，等待其完全退出
 func StopBackgroundMonitoring() {
 	close(monitoringStopChan) // 发送停止信号
@@ -832,7 +1138,7 @@ func CloseAllWebSockets() {
 	for client := range wsClients {
 		client.Close()
 	}
-	wsClients = make(map[*websocket.Conn]bool)
+	wsClients = make(map[*websocket.Conn]*wsSubscription)
 	log.Println("所有 WebSocket 连接已关闭")
 }
 
@@ -878,10 +1184,8 @@ func recordRequestTrace(requestID string, resp *service.DeductResponse, amount i
 
 // tryCapture冲突Snapshot 尝试捕获冲突快照
 func tryCapture冲突Snapshot() {
-	// 获取当前模式
-	modeMutex.RLock()
-	currentLockMode := useLockMode
-	modeMutex.RUnlock()
+	// 获取当前策略
+	strategyName := getCurrentStrategy()
 
 	// 找出所有读到相同值的请求
 	valueMap := make(map[int64][]*RequestTrace)
@@ -924,10 +1228,11 @@ func tryCapture冲突Snapshot() {
 				DB_InitialValue: readValue,
 				DB_AfterA:       traceA.WriteValue,
 				DB_AfterB:       traceB.WriteValue,
-				DB_ExpectedB:    traceA.WriteValue - traceB.Amount, // 如果B基于A的结果计算
-				IsConflict:      true,                              // B读到了旧值
+				DB_ExpectedB:    traceA.WriteValue + traceB.NewValue - traceB.ReadValue, // 如果B基于A的结果计算（扣款和入账都适用）
+				IsConflict:      true,                                                   // B读到了旧值
 				LostAmount:      traceA.WriteValue - traceB.WriteValue,
-				UseLock:         currentLockMode,
+				UseLock:         strategyName != service.StrategyUnlocked,
+				Strategy:        strategyName,
 				CapturedAt:      time.Now().UnixMilli(),
 				Amount:          traceA.Amount,
 			}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"zero-balance-loss/service"
+)
+
+func TestDeductErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrInsufficientBalance, http.StatusBadRequest},
+		{&service.PolicyError{Code: service.PolicyCodeDailyLimitExceeded}, http.StatusBadRequest},
+		{service.ErrInvalidAmount, http.StatusBadRequest},
+		{fmt.Errorf("%w: %q", service.ErrTransferUnsupported, "actor"), http.StatusBadRequest},
+		{fmt.Errorf("failed to get account: %w", service.ErrAccountNotFound), http.StatusNotFound},
+		{service.ErrAccountClosed, http.StatusConflict},
+		{service.ErrLockTimeout, http.StatusConflict},
+		{service.ErrOptimisticConflict, http.StatusConflict},
+		{service.ErrQueueFull, http.StatusTooManyRequests},
+		{service.ErrRedisUnavailable, http.StatusServiceUnavailable},
+		{context.Canceled, http.StatusServiceUnavailable},
+		{fmt.Errorf("failed to update balance: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{errors.New("driver: bad connection"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := deductErrorStatus(tt.err); got != tt.want {
+			t.Errorf("deductErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// createHoldHandler 冻结金额（预授权）
+// 请求体中的 strategy 为空时使用当前扣款策略；可用余额不足返回 400
+func createHoldHandler(c *gin.Context) {
+	var req service.HoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: amount must be positive",
+		})
+		return
+	}
+
+	if !checkHoldAccount(c, req.UserID) {
+		return
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = getCurrentStrategy()
+	}
+	requestID := uuid.New().String()[:8]
+
+	resp, err := accountService.Authorize(c.Request.Context(), strategy, &req, requestID)
+	respondHold(c, req.UserID, requestID, resp, err)
+}
+
+// captureHoldHandler 对冻结扣款，请求体可省略，amount 为 0 时扣除全部冻结金额
+func captureHoldHandler(c *gin.Context) {
+	holdID, ok := parseHoldIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.CaptureRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+	if req.Amount < 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: amount must not be negative",
+		})
+		return
+	}
+
+	hold, err := accountService.GetHold(c.Request.Context(), holdID)
+	if err != nil {
+		respondHoldError(c, err, "")
+		return
+	}
+	if !checkHoldAccount(c, hold.UserID) {
+		return
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = getCurrentStrategy()
+	}
+	requestID := uuid.New().String()[:8]
+
+	resp, err := accountService.Capture(c.Request.Context(), strategy, holdID, &req, requestID)
+	respondHold(c, hold.UserID, requestID, resp, err)
+}
+
+// voidHoldHandler 撤销冻结，释放冻结金额
+func voidHoldHandler(c *gin.Context) {
+	holdID, ok := parseHoldIDParam(c)
+	if !ok {
+		return
+	}
+
+	hold, err := accountService.GetHold(c.Request.Context(), holdID)
+	if err != nil {
+		respondHoldError(c, err, "")
+		return
+	}
+	requestID := uuid.New().String()[:8]
+
+	resp, err := accountService.Void(c.Request.Context(), holdID, requestID)
+	respondHold(c, hold.UserID, requestID, resp, err)
+}
+
+// getHoldHandler 查询冻结记录
+func getHoldHandler(c *gin.Context) {
+	holdID, ok := parseHoldIDParam(c)
+	if !ok {
+		return
+	}
+
+	hold, err := accountService.GetHold(c.Request.Context(), holdID)
+	if err != nil {
+		respondHoldError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    hold,
+	})
+}
+
+// listHoldsHandler 分页查询账户的冻结记录
+// 查询参数：?user_id=&status=active|captured|voided|expired&cursor=<上一页的 next_cursor>&limit=<每页条数>，按 ID 升序返回
+func listHoldsHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid user_id",
+		})
+		return
+	}
+
+	var cursor int64
+	if s := c.Query("cursor"); s != "" {
+		cursor, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid cursor",
+			})
+			return
+		}
+	}
+
+	limit := 0
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid limit",
+			})
+			return
+		}
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", model.HoldStatusActive, model.HoldStatusCaptured, model.HoldStatusVoided, model.HoldStatusExpired:
+	default:
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid status",
+		})
+		return
+	}
+
+	page, err := accountService.ListHolds(c.Request.Context(), userID, status, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    page,
+	})
+}
+
+// parseHoldIDParam 解析路径参数 id，不合法时直接返回 400
+func parseHoldIDParam(c *gin.Context) (int64, bool) {
+	holdID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || holdID <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid hold id",
+		})
+		return 0, false
+	}
+	return holdID, true
+}
+
+// checkHoldAccount 冻结和扣款前检查账户，不存在或已关闭时直接返回错误
+func checkHoldAccount(c *gin.Context, userID int64) bool {
+	account, err := accountService.GetAccount(userID)
+	if err == nil && account.Closed() {
+		err = service.ErrAccountClosed
+	}
+	if err != nil {
+		respondAccountError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondHold 更新统计、向账户订阅者推送冻结变化并返回结果
+func respondHold(c *gin.Context, userID int64, requestID string, resp *service.HoldResponse, err error) {
+	statsMutex.Lock()
+	stats.TotalRequests++
+	if err != nil {
+		stats.FailureCount++
+		stats.ErrorClasses[service.ClassifyError(err)]++
+	} else {
+		stats.SuccessCount++
+		stats.RetryCount += int64(resp.Retries)
+	}
+	statsMutex.Unlock()
+
+	if err != nil {
+		respondHoldError(c, err, requestID)
+		return
+	}
+
+	broadcastToSubscribers(userID, WSMessage{
+		Type:      "hold_update",
+		Data:      resp,
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// respondHoldError 将冻结相关错误映射为 HTTP 状态码
+// 冻结不存在返回 404，已扣款、已撤销或已过期返回 409，其余与扣款一致
+func respondHoldError(c *gin.Context, err error, requestID string) {
+	var status int
+	switch {
+	case errors.Is(err, service.ErrHoldNotFound), errors.Is(err, service.ErrAccountNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrHoldNotActive), errors.Is(err, service.ErrHoldExpired):
+		status = http.StatusConflict
+	default:
+		status = deductErrorStatus(err)
+	}
+
+	resp := Response{
+		Code:    status,
+		Message: err.Error(),
+	}
+	if requestID != "" {
+		data := map[string]interface{}{
+			"request_id":  requestID,
+			"error_class": service.ClassifyError(err),
+		}
+		var policyErr *service.PolicyError
+		if errors.As(err, &policyErr) {
+			data["policy_violation"] = policyErr
+		}
+		resp.Data = data
+	}
+	c.JSON(status, resp)
+}
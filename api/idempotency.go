@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyKeyHeader 客户端传入幂等键的请求头
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader 响应来自缓存时附加的响应头
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength 幂等键最大长度，与数据库列宽一致
+	maxIdempotencyKeyLength = 255
+)
+
+// responseRecorder 在写给客户端的同时记录响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyMiddleware 处理 Idempotency-Key 请求头，未携带时不做任何事
+// 相同 key、相同请求体的重试返回第一次的响应；相同 key、不同请求体返回 422
+func idempotencyMiddleware(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" || idempotency == nil {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Idempotency-Key too long",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "failed to read request body",
+		})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	fingerprint := service.IdempotencyFingerprint(c.Request.Method, c.FullPath(), body)
+	replay, err := idempotency.Begin(c.Request.Context(), key, fingerprint)
+	switch {
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, Response{
+			Code:    422,
+			Message: err.Error(),
+		})
+		return
+	case errors.Is(err, service.ErrIdempotencyInProgress):
+		c.AbortWithStatusJSON(http.StatusConflict, Response{
+			Code:    409,
+			Message: err.Error(),
+		})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if replay != nil {
+		c.Header(idempotentReplayedHeader, "true")
+		c.Data(replay.StatusCode, "application/json; charset=utf-8", []byte(replay.ResponseBody))
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	defer func() {
+		// handler panic 时（由外层的 gin.Recovery 转换为 500）按服务端错误释放 key，客户端可以重试
+		if p := recover(); p != nil {
+			idempotency.Finish(key, http.StatusInternalServerError, nil)
+			panic(p)
+		}
+		idempotency.Finish(key, recorder.Status(), recorder.body.Bytes())
+	}()
+	c.Next()
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"zero-balance-loss/repository"
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestAPI 注入基于内存存储的账户服务和幂等键处理，返回只带 recovery 中间件的路由
+// 测试结束后恢复包级变量
+func newTestAPI(t *testing.T, strategy string) (*gin.Engine, *service.AccountService) {
+	t.Helper()
+	svc := service.NewAccountService(repository.NewMemoryAccountRepository(repository.MemoryOptions{}))
+
+	prevService, prevIdempotency, prevStrategy := accountService, idempotency, getCurrentStrategy()
+	accountService = svc
+	idempotency = service.NewIdempotencyService(repository.NewMemoryIdempotencyStore())
+	modeMutex.Lock()
+	currentStrategy = strategy
+	modeMutex.Unlock()
+	t.Cleanup(func() {
+		accountService, idempotency = prevService, prevIdempotency
+		modeMutex.Lock()
+		currentStrategy = prevStrategy
+		modeMutex.Unlock()
+	})
+
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	return r, svc
+}
+
+// postJSON 发送带幂等键的 JSON 请求
+func postJSON(r http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyConcurrentSameKeyDeductsOnce(t *testing.T) {
+	r, svc := newTestAPI(t, service.StrategyMutex)
+	r.POST("/api/deduct", idempotencyMiddleware, deductHandler)
+	if _, err := svc.CreateAccount(context.Background(), 1, 10000); err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = postJSON(r, "/api/deduct", "same-key", `{"user_id":1,"amount":100}`)
+		}(i)
+	}
+	wg.Wait()
+
+	replayed := 0
+	for i, w := range responses {
+		if w.Code != http.StatusOK {
+			t.Fatalf("response %d: status = %d, body = %s", i, w.Code, w.Body.String())
+		}
+		if w.Header().Get(idempotentReplayedHeader) == "true" {
+			replayed++
+		}
+		if w.Body.String() != responses[0].Body.String() {
+			t.Errorf("response %d body differs from the stored response:\n%s\n%s", i, w.Body.String(), responses[0].Body.String())
+		}
+	}
+	if replayed != n-1 {
+		t.Errorf("replayed responses = %d, want %d", replayed, n-1)
+	}
+
+	balance, err := svc.GetBalance(1)
+	if err != nil {
+		t.Fatalf("get balance: %v", err)
+	}
+	if balance != 9900 {
+		t.Fatalf("balance = %d, want 9900 (deducted exactly once)", balance)
+	}
+}
+
+func TestIdempotencyPanicReleasesKey(t *testing.T) {
+	r, _ := newTestAPI(t, service.StrategyMutex)
+
+	var calls int32
+	r.POST("/api/flaky", idempotencyMiddleware, func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, Response{Code: 200, Message: "success"})
+	})
+
+	if w := postJSON(r, "/api/flaky", "panic-key", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request: status = %d, want 500", w.Code)
+	}
+
+	// panic 后 key 已释放，重试会重新执行而不是等待 409
+	w := postJSON(r, "/api/flaky", "panic-key", `{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry after panic: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatal("retry after panic was replayed instead of executed")
+	}
+	if calls != 2 {
+		t.Fatalf("handler calls = %d, want 2", calls)
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runReconcileHandler 立即执行一次对账
+func runReconcileHandler(c *gin.Context) {
+	run, err := reconciler.Run(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    run,
+	})
+}
+
+// listReconcileReportsHandler 分页查询对账差异报告
+// 查询参数：?cursor=<上一页的 next_cursor>&limit=<每页条数>，按 ID 从新到旧返回
+func listReconcileReportsHandler(c *gin.Context) {
+	var cursor int64
+	var err error
+	if s := c.Query("cursor"); s != "" {
+		cursor, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid cursor",
+			})
+			return
+		}
+	}
+
+	limit := 0
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid limit",
+			})
+			return
+		}
+	}
+
+	page, err := reconciler.Reports(c.Request.Context(), cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    page,
+	})
+}
+
+// broadcastReconcile 广播对账结果
+func broadcastReconcile(run *service.ReconcileRun) {
+	broadcast(WSMessage{
+		Type:      "reconcile",
+		Data:      run,
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultSubscribedUserID 未指定 user_id 时订阅的账户
+const defaultSubscribedUserID = 1
+
+// wsSubscription 一个 WebSocket 客户端订阅的账户集合，读写都在 wsMutex 保护下进行
+type wsSubscription struct {
+	userIDs map[int64]bool
+}
+
+// newWSSubscription 创建订阅，userIDs 为空时订阅默认账户
+func newWSSubscription(userIDs []int64) *wsSubscription {
+	sub := &wsSubscription{userIDs: make(map[int64]bool)}
+	if len(userIDs) == 0 {
+		userIDs = []int64{defaultSubscribedUserID}
+	}
+	for _, id := range userIDs {
+		sub.userIDs[id] = true
+	}
+	return sub
+}
+
+// has 是否订阅了 userID
+func (s *wsSubscription) has(userID int64) bool {
+	return s != nil && s.userIDs[userID]
+}
+
+// list 按账户 ID 升序返回订阅的账户
+func (s *wsSubscription) list() []int64 {
+	ids := make([]int64, 0, len(s.userIDs))
+	for id := range s.userIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// wsClientMessage 客户端发来的订阅消息
+// {"type":"subscribe","user_ids":[2,3]} / {"type":"unsubscribe","user_ids":[1]}
+type wsClientMessage struct {
+	Type    string  `json:"type"`
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// parseUserIDs 解析逗号分隔的账户 ID 列表，如 "1,2,3"；空字符串返回 nil
+func parseUserIDs(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, strconv.ErrSyntax
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// subscribedUserIDs 所有客户端订阅账户的并集，按账户 ID 升序
+// 后台监控只轮询这些账户
+func subscribedUserIDs() []int64 {
+	wsMutex.Lock()
+	defer wsMutex.Unlock()
+
+	set := make(map[int64]bool)
+	for _, sub := range wsClients {
+		for id := range sub.userIDs {
+			set[id] = true
+		}
+	}
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// sendInitialState 向新订阅的账户各发送一条 init 消息（当前余额和统计）
+// 不存在的账户只记录日志，不影响其他账户
+func sendInitialState(conn *websocket.Conn, userIDs []int64) {
+	if len(userIDs) == 0 {
+		userIDs = []int64{defaultSubscribedUserID}
+	}
+	currentStats := snapshotStats()
+	for _, userID := range userIDs {
+		balance, err := accountService.GetBalance(userID)
+		if err != nil {
+			log.Printf("查询余额失败: user_id=%d, %v", userID, err)
+			continue
+		}
+
+		wsMutex.Lock()
+		if _, ok := wsClients[conn]; ok {
+			writeWSMessage(conn, WSMessage{
+				Type: "init",
+				Data: map[string]interface{}{
+					"user_id": userID,
+					"balance": balance,
+					"stats":   currentStats,
+				},
+				Timestamp: time.Now().UnixMilli(),
+			})
+		}
+		wsMutex.Unlock()
+	}
+}
+
+// handleWSClientMessage 处理客户端发来的 subscribe/unsubscribe 消息，并回复当前订阅列表
+func handleWSClientMessage(conn *websocket.Conn, data []byte) {
+	var msg wsClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	var added []int64
+	wsMutex.Lock()
+	sub, ok := wsClients[conn]
+	if !ok {
+		wsMutex.Unlock()
+		return
+	}
+	switch msg.Type {
+	case "subscribe":
+		for _, id := range msg.UserIDs {
+			if id > 0 && !sub.userIDs[id] {
+				sub.userIDs[id] = true
+				added = append(added, id)
+			}
+		}
+	case "unsubscribe":
+		for _, id := range msg.UserIDs {
+			delete(sub.userIDs, id)
+		}
+	default:
+		wsMutex.Unlock()
+		return
+	}
+	writeWSMessage(conn, WSMessage{
+		Type: "subscribed",
+		Data: map[string]interface{}{
+			"user_ids": sub.list(),
+		},
+		Timestamp: time.Now().UnixMilli(),
+	})
+	wsMutex.Unlock()
+
+	if len(added) > 0 {
+		sendInitialState(conn, added)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listTransactionsHandler 分页查询账户流水
+// 查询参数：?cursor=<上一页的 next_cursor>&limit=<每页条数>，按 ID 从新到旧返回
+func listTransactionsHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid user_id",
+		})
+		return
+	}
+
+	var cursor int64
+	if s := c.Query("cursor"); s != "" {
+		cursor, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid cursor",
+			})
+			return
+		}
+	}
+
+	limit := 0
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Message: "invalid limit",
+			})
+			return
+		}
+	}
+
+	page, err := accountService.ListTransactions(c.Request.Context(), userID, cursor, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    page,
+	})
+}
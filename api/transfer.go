@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"zero-balance-loss/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// transferHandler 转账接口
+// 请求体中的 strategy 为空时使用当前扣款策略；不支持转账的策略返回 400
+func transferHandler(c *gin.Context) {
+	var req service.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: " + err.Error(),
+		})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "invalid request: amount must be positive",
+		})
+		return
+	}
+	if req.FromUserID == req.ToUserID {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: service.ErrSameAccount.Error(),
+		})
+		return
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = getCurrentStrategy()
+	}
+
+	requestID := uuid.New().String()[:8]
+
+	statsMutex.Lock()
+	stats.TotalRequests++
+	statsMutex.Unlock()
+
+	// Step 1: 读取两个账户，已关闭或不存在的账户直接拒绝
+	balances := make(map[int64]int64, 2)
+	for _, userID := range []int64{req.FromUserID, req.ToUserID} {
+		account, err := accountService.GetAccount(userID)
+		if err == nil && account.Closed() {
+			err = service.ErrAccountClosed
+		}
+		if err != nil {
+			statsMutex.Lock()
+			stats.FailureCount++
+			statsMutex.Unlock()
+
+			respondAccountError(c, err)
+			return
+		}
+		balances[userID] = account.Balance
+	}
+
+	broadcastTransferTrace(&req, requestID, 1, "读取余额", balances[req.FromUserID], balances[req.ToUserID], nil)
+
+	// Step 2: 按策略执行转账
+	resp, err := accountService.Transfer(c.Request.Context(), strategy, &req, requestID)
+	if err != nil {
+		errorClass := service.ClassifyError(err)
+		retries := 0
+		var retryErr *service.RetryError
+		if errors.As(err, &retryErr) {
+			errorClass = retryErr.Class
+			retries = retryErr.Retries
+		}
+
+		statsMutex.Lock()
+		stats.FailureCount++
+		stats.RetryCount += int64(retries)
+		stats.ErrorClasses[errorClass]++
+		statsMutex.Unlock()
+
+		broadcastTransferTrace(&req, requestID, 2, "转账失败", balances[req.FromUserID], balances[req.ToUserID], nil)
+
+		data := map[string]interface{}{
+			"request_id":  requestID,
+			"retries":     retries,
+			"error_class": errorClass,
+		}
+		var policyErr *service.PolicyError
+		if errors.As(err, &policyErr) {
+			data["policy_violation"] = policyErr
+		}
+
+		status := deductErrorStatus(err)
+		c.JSON(status, Response{
+			Code:    status,
+			Message: err.Error(),
+			Data:    data,
+		})
+		return
+	}
+
+	statsMutex.Lock()
+	stats.SuccessCount++
+	stats.RetryCount += int64(resp.Retries)
+	statsMutex.Unlock()
+
+	// Step 3: 写入完成
+	broadcastTransferTrace(&req, requestID, 3, "写入完成", resp.FromOldBalance, resp.ToOldBalance, resp)
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    resp,
+	})
+}
+
+// broadcastTransferTrace 向转出、转入两个账户的订阅者分别广播追踪事件
+// 转出账户看到的是一次扣款，转入账户看到的是一次入账；resp 不为空时附带写入后的余额
+func broadcastTransferTrace(req *service.TransferRequest, requestID string, step int, stepName string, fromBalance, toBalance int64, resp *service.TransferResponse) {
+	now := time.Now().UnixMilli()
+	from := TraceEvent{
+		UserID:    req.FromUserID,
+		RequestID: requestID,
+		Step:      step,
+		StepName:  stepName,
+		Balance:   fromBalance,
+		Amount:    req.Amount,
+		Timestamp: now,
+	}
+	to := TraceEvent{
+		UserID:    req.ToUserID,
+		RequestID: requestID,
+		Step:      step,
+		StepName:  stepName,
+		Balance:   toBalance,
+		Amount:    req.Amount,
+		Credit:    true,
+		Timestamp: now,
+	}
+	if resp != nil {
+		from.NewBalance = resp.FromBalance
+		to.NewBalance = resp.ToBalance
+	}
+	broadcastTrace(from)
+	broadcastTrace(to)
+}
+
+// getInvariantHandler 检查全部账户余额之和是否守恒
+func getInvariantHandler(c *gin.Context) {
+	inv, err := accountService.CheckBalanceInvariant(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Message: "success",
+		Data:    inv,
+	})
+}
@@ -4,16 +4,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Kafka       KafkaConfig       `yaml:"kafka"`
+	Strategy    StrategyConfig    `yaml:"strategy"`
+	Reconcile   ReconcileConfig   `yaml:"reconcile"`
+	Holds       HoldsConfig       `yaml:"holds"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
 }
 
 // ServerConfig 服务器配置
@@ -24,13 +29,25 @@ type ServerConfig struct {
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Host         string `yaml:"host"`
-	Port         int    `yaml:"port"`
-	User         string `yaml:"user"`
-	Password     string `yaml:"password"`
-	Database     string `yaml:"database"`
-	MaxIdleConns int    `yaml:"max_idle_conns"`
-	MaxOpenConns int    `yaml:"max_open_conns"`
+	Driver       string         `yaml:"driver"`       // mysql（默认）/ postgres / sqlite / memory
+	Path         string         `yaml:"path"`         // SQLite 文件路径，":memory:" 表示内存库
+	SeedFile     string         `yaml:"seed_file"`    // 启动时加载的初始账户（已存在的不覆盖），默认 scripts/seed.sql
+	SkipMigrate  bool           `yaml:"skip_migrate"` // 启动时不自动执行迁移，改用 migrate 子命令
+	Host         string         `yaml:"host"`
+	Port         int            `yaml:"port"`
+	User         string         `yaml:"user"`
+	Password     string         `yaml:"password"`
+	Database     string         `yaml:"database"`
+	MaxIdleConns int            `yaml:"max_idle_conns"`
+	MaxOpenConns int            `yaml:"max_open_conns"`
+	SSLMode      string         `yaml:"sslmode"` // 仅 PostgreSQL，默认 disable
+	Memory       MemoryDBConfig `yaml:"memory"`
+}
+
+// MemoryDBConfig 内存存储配置，仅 driver 为 memory 时生效
+type MemoryDBConfig struct {
+	ReadLatencyMs  int `yaml:"read_latency_ms"`  // 每次读取的模拟延迟
+	WriteLatencyMs int `yaml:"write_latency_ms"` // 每次写入的模拟延迟
 }
 
 // RedisConfig Redis配置
@@ -49,6 +66,86 @@ type KafkaConfig struct {
 	ConsumerGroup string            `yaml:"consumer_group"`
 }
 
+// StrategyConfig 扣款策略配置
+type StrategyConfig struct {
+	Optimistic   OptimisticConfig   `yaml:"optimistic"`
+	Pessimistic  PessimisticConfig  `yaml:"pessimistic"`
+	RedisLock    RedisLockConfig    `yaml:"redis_lock"`
+	AdvisoryLock AdvisoryLockConfig `yaml:"advisory_lock"`
+	Actor        ActorConfig        `yaml:"actor"`
+	Batch        BatchConfig        `yaml:"batch"`
+	Serializable SerializableConfig `yaml:"serializable"`
+}
+
+// OptimisticConfig 乐观锁策略配置
+type OptimisticConfig struct {
+	MaxAttempts  int `yaml:"max_attempts"`   // 最大尝试次数（含首次）
+	BackoffMs    int `yaml:"backoff_ms"`     // 首次重试前的退避时间，之后按指数增长
+	MaxBackoffMs int `yaml:"max_backoff_ms"` // 单次退避上限
+}
+
+// PessimisticConfig 悲观锁（SELECT ... FOR UPDATE）策略配置
+type PessimisticConfig struct {
+	IsolationLevel     string `yaml:"isolation_level"`       // 留空使用数据库默认；READ UNCOMMITTED / READ COMMITTED / REPEATABLE READ / SERIALIZABLE
+	LockWaitTimeoutSec int    `yaml:"lock_wait_timeout_sec"` // 行锁等待超时（innodb_lock_wait_timeout），单位秒
+}
+
+// RedisLockConfig Redis 分布式锁策略配置
+type RedisLockConfig struct {
+	KeyPrefix        string `yaml:"key_prefix"`         // 锁 key 前缀，完整 key 为 <prefix><user_id>
+	TTLMs            int    `yaml:"ttl_ms"`             // 锁租约时长，看门狗每 ttl/3 续期一次
+	AcquireTimeoutMs int    `yaml:"acquire_timeout_ms"` // 获取锁的最长等待时间
+	RetryIntervalMs  int    `yaml:"retry_interval_ms"`  // 获取失败后的重试间隔
+}
+
+// AdvisoryLockConfig MySQL 用户级锁（GET_LOCK）策略配置
+type AdvisoryLockConfig struct {
+	TimeoutSec int `yaml:"timeout_sec"` // GET_LOCK 等待超时，单位秒
+}
+
+// ActorConfig 单写者（每账户一个协程）策略配置
+type ActorConfig struct {
+	QueueSize int `yaml:"queue_size"`  // 每个账户请求队列的容量，队列满时直接拒绝
+	IdleTTLMs int `yaml:"idle_ttl_ms"` // 账户协程空闲多久后回收
+}
+
+// BatchConfig 批量合并提交（group commit）策略配置
+type BatchConfig struct {
+	WindowMs int `yaml:"window_ms"` // 收集窗口，第一个请求到达后最多等待多久
+	MaxBatch int `yaml:"max_batch"` // 单批最多合并多少个请求，达到即立即提交
+}
+
+// SerializableConfig 串行化隔离级别策略配置
+type SerializableConfig struct {
+	MaxAttempts        int `yaml:"max_attempts"`          // 最大尝试次数（含首次）
+	BackoffMs          int `yaml:"backoff_ms"`            // 首次重试的退避基准，之后指数增长并加随机抖动
+	MaxBackoffMs       int `yaml:"max_backoff_ms"`        // 单次退避上限
+	LockWaitTimeoutSec int `yaml:"lock_wait_timeout_sec"` // 行锁等待超时，单位秒
+}
+
+// ReconcileConfig 对账任务配置
+type ReconcileConfig struct {
+	Enabled     bool `yaml:"enabled"`      // 是否启用后台定时对账，POST /api/reconcile 不受影响
+	IntervalSec int  `yaml:"interval_sec"` // 后台对账间隔，单位秒
+}
+
+// HoldsConfig 预授权冻结配置
+type HoldsConfig struct {
+	DefaultTTLSec    int `yaml:"default_ttl_sec"`    // 创建冻结时未指定 ttl_sec 的默认有效期，单位秒
+	MaxTTLSec        int `yaml:"max_ttl_sec"`        // 允许的最长有效期，单位秒
+	SweepIntervalSec int `yaml:"sweep_interval_sec"` // 后台释放过期冻结的间隔，单位秒
+	SweepBatchSize   int `yaml:"sweep_batch_size"`   // 每次最多释放的过期冻结数
+}
+
+// IdempotencyConfig 幂等键（Idempotency-Key 请求头）配置
+type IdempotencyConfig struct {
+	Store         string `yaml:"store"`           // memory（默认）/ db / redis
+	TTLSec        int    `yaml:"ttl_sec"`         // 幂等键保存时长，过期后同一个 key 会被当作新请求
+	LeaseSec      int    `yaml:"lease_sec"`       // 处理中的幂等键租约，进程崩溃未完成的 key 过期后可以重新执行
+	WaitTimeoutMs int    `yaml:"wait_timeout_ms"` // 相同 key 的请求仍在处理时最多等待多久，超时返回 409
+	KeyPrefix     string `yaml:"key_prefix"`      // 仅 redis：key 前缀
+}
+
 var AppConfig *Config
 
 // LoadConfig 加载配置文件，并用环境变量覆盖敏感配置
@@ -75,8 +172,21 @@ func LoadConfig(configPath string) error {
 	if dbHost := os.Getenv("DB_HOST"); dbHost != "" {
 		AppConfig.Database.Host = dbHost
 	}
+	if dbPort := os.Getenv("DB_PORT"); dbPort != "" {
+		port, err := strconv.Atoi(dbPort)
+		if err != nil {
+			return fmt.Errorf("invalid DB_PORT %q: %w", dbPort, err)
+		}
+		AppConfig.Database.Port = port
+	}
+	if dbDriver := os.Getenv("DB_DRIVER"); dbDriver != "" {
+		AppConfig.Database.Driver = dbDriver
+	}
+	if AppConfig.Database.Driver == "" {
+		AppConfig.Database.Driver = DriverMySQL
+	}
 
-	log.Printf("Config loaded: mode=%s, db_host=%s", AppConfig.Server.Mode, AppConfig.Database.Host)
+	log.Printf("Config loaded: mode=%s, db_driver=%s, db_host=%s", AppConfig.Server.Mode, AppConfig.Database.Driver, AppConfig.Database.Host)
 	return nil
 }
 
@@ -5,10 +5,23 @@ import (
 	"log"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// 支持的数据库驱动（database.driver）
+const (
+	DriverMySQL    = "mysql"    // MySQL，默认
+	DriverPostgres = "postgres" // PostgreSQL
+	DriverSQLite   = "sqlite"   // SQLite 文件或 :memory:，不需要 Docker
+	DriverMemory   = "memory"   // 进程内存储，不需要任何外部服务
+)
+
+// defaultSQLitePath database.path 未配置时的 SQLite 文件
+const defaultSQLitePath = "zero_balance_loss.db"
+
 var DB *gorm.DB
 
 // InitDB 初始化数据库连接
@@ -20,16 +33,39 @@ func InitDB() {
 
 	dbConfig := AppConfig.Database
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		dbConfig.User,
-		dbConfig.Password,
-		dbConfig.Host,
-		dbConfig.Port,
-		dbConfig.Database,
-	)
+	var dialector gorm.Dialector
+	switch dbConfig.Driver {
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			dbConfig.User,
+			dbConfig.Password,
+			dbConfig.Host,
+			dbConfig.Port,
+			dbConfig.Database,
+		)
+		dialector = mysql.Open(dsn)
+	case DriverPostgres:
+		sslMode := dbConfig.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			dbConfig.Host,
+			dbConfig.Port,
+			dbConfig.User,
+			dbConfig.Password,
+			dbConfig.Database,
+			sslMode,
+		)
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(dbConfig.Path))
+	default:
+		log.Fatalf("Unsupported database driver for InitDB: %q", dbConfig.Driver)
+	}
 
 	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 
@@ -44,8 +80,34 @@ func InitDB() {
 	}
 	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	if dbConfig.Driver == DriverSQLite && isSQLiteMemory(dbConfig.Path) {
+		// 每条连接都会打开一个独立的内存库，只能使用单连接
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
+
+	log.Printf("Database connected successfully (driver=%s)", dbConfig.Driver)
+}
+
+// sqliteDSN 生成 SQLite 连接串
+// _txlock=immediate 让事务开始即持有写锁，代替 SQLite 不支持的 SELECT ... FOR UPDATE；
+// _busy_timeout 是等待写锁的最长时间，相当于 MySQL 的锁等待超时
+func sqliteDSN(path string) string {
+	if path == "" {
+		path = defaultSQLitePath
+	}
+	params := "_txlock=immediate&_busy_timeout=5000"
+	if !isSQLiteMemory(path) {
+		// WAL 模式下读写互不阻塞，更接近 InnoDB 的一致性读
+		params += "&_journal_mode=WAL"
+	}
+	return fmt.Sprintf("file:%s?%s", path, params)
+}
 
-	log.Println("Database connected successfully")
+// isSQLiteMemory 是否为 SQLite 内存库
+func isSQLiteMemory(path string) bool {
+	return path == ":memory:"
 }
 
 // CloseDB 关闭数据库连接
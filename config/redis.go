@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var RedisClient redis.UniversalClient
+
+// InitRedis 初始化 Redis 连接，启动时紧接 InitDB 调用
+// Redis 只被 redis 策略和 redis 幂等存储使用，连接失败不阻止服务启动：
+// 客户端会在后续请求时自动重连，未启动 Redis 时只有用到它的功能不可用
+func InitRedis() {
+	if AppConfig == nil {
+		log.Fatal("Config not loaded. Please call LoadConfig first.")
+	}
+
+	redisConfig := AppConfig.Redis
+
+	RedisClient = redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port),
+		Password: redisConfig.Password,
+		DB:       redisConfig.DB,
+		PoolSize: redisConfig.PoolSize,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := RedisClient.Ping(ctx).Err(); err != nil {
+		log.Printf("Redis not reachable (redis strategy unavailable until it is): %v", err)
+		return
+	}
+
+	log.Println("Redis connected successfully")
+}
+
+// CloseRedis 关闭 Redis 连接
+func CloseRedis() {
+	if RedisClient != nil {
+		if err := RedisClient.Close(); err != nil {
+			log.Printf("Failed to close redis: %v", err)
+		}
+	}
+}
+
+// GetRedis 获取 Redis 客户端，InitRedis 之前返回 nil
+func GetRedis() redis.UniversalClient {
+	return RedisClient
+}
@@ -12,6 +12,8 @@ import (
 
 	"zero-balance-loss/api"
 	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+	"zero-balance-loss/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,17 +23,29 @@ func main() {
 	if err := config.LoadConfig("config.yaml"); err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-
-	// 2. 初始化数据库连接
 	cfg := config.GetConfig()
-	config.InitDB()
 
-	// 3. 创建路由并注册
+	// migrate 子命令：只执行数据库迁移，不启动服务
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(cfg, os.Args[2:]))
+	}
+
+	// 2. 初始化数据库连接
+	repo, reports := newRepositories(cfg)
+	config.InitRedis()
+
+	// 3. 创建账户服务、对账任务、过期冻结释放任务和幂等键处理，创建路由并注册
+	accountService := service.NewAccountService(repo)
+	reconciler := service.NewReconciler(accountService, reports)
+	holdSweeper := service.NewHoldSweeper(accountService)
+	idempotency := service.NewIdempotencyService(newIdempotencyStore(cfg))
 	r := gin.Default()
-	api.RegisterRoutes(r)
+	api.RegisterRoutes(r, accountService, reconciler, idempotency)
 
-	// 4. 启动后台监控任务（可控的，能被优雅停止）
+	// 4. 启动后台监控、对账和过期冻结释放任务（可控的，能被优雅停止）
 	api.StartBackgroundMonitoring()
+	reconciler.Start()
+	holdSweeper.Start()
 
 	// 5. 创建 HTTP Server（不用 gin.Run，这样才能优雅关闭）
 	port := fmt.Sprintf(":%d", cfg.Server.Port)
@@ -61,13 +75,78 @@ func main() {
 	fmt.Println("收到信号:", sig, "正在清理资源...")
 
 	// 8. 执行优雅关闭
-	gracefulShutdown(srv)
+	gracefulShutdown(srv, reconciler, holdSweeper)
+}
+
+// newRepositories 根据 database.driver 创建账户存储和对账报告存储（同一后端）
+func newRepositories(cfg *config.Config) (repository.AccountRepository, repository.ReconciliationRepository) {
+	switch cfg.Database.Driver {
+	case config.DriverMySQL, config.DriverPostgres, config.DriverSQLite:
+		config.InitDB()
+		migrateOnStart(cfg)
+		repo := repository.NewGormAccountRepository(config.GetDB())
+		seedAccounts(cfg, repo)
+		return repo, repo
+
+	case config.DriverMemory:
+		memCfg := cfg.Database.Memory
+		repo := repository.NewMemoryAccountRepository(repository.MemoryOptions{
+			ReadLatency:  time.Duration(memCfg.ReadLatencyMs) * time.Millisecond,
+			WriteLatency: time.Duration(memCfg.WriteLatencyMs) * time.Millisecond,
+		})
+		seedAccounts(cfg, repo)
+		return repo, repo
+
+	default:
+		log.Fatalf("Unsupported database driver: %q", cfg.Database.Driver)
+		return nil, nil
+	}
+}
+
+// newIdempotencyStore 根据 idempotency.store 创建幂等键存储
+// db 与账户使用同一个数据库，database.driver 为 memory 时退化为进程内存储
+func newIdempotencyStore(cfg *config.Config) repository.IdempotencyStore {
+	settings := service.IdempotencySettings()
+	switch settings.Store {
+	case "memory":
+		return repository.NewMemoryIdempotencyStore()
+
+	case "db":
+		if cfg.Database.Driver == config.DriverMemory {
+			log.Println("database.driver 为 memory，幂等键改用进程内存储")
+			return repository.NewMemoryIdempotencyStore()
+		}
+		return repository.NewGormIdempotencyStore(config.GetDB())
+
+	case "redis":
+		return repository.NewRedisIdempotencyStore(config.GetRedis(), settings.KeyPrefix)
+
+	default:
+		log.Fatalf("Unsupported idempotency store: %q", settings.Store)
+		return nil
+	}
+}
+
+// seedAccounts 加载 database.seed_file 中的初始账户，已存在的账户保持不变
+func seedAccounts(cfg *config.Config, repo repository.AccountSeeder) {
+	seedFile := cfg.Database.SeedFile
+	if seedFile == "" {
+		seedFile = "scripts/seed.sql"
+	}
+	accounts, err := repository.LoadSeedSQL(seedFile)
+	if err != nil {
+		log.Fatalf("Failed to load seed data: %v", err)
+	}
+	if err := repo.Seed(context.Background(), accounts); err != nil {
+		log.Fatalf("Failed to seed accounts: %v", err)
+	}
+	log.Printf("Seeded %d accounts from %s (driver=%s)", len(accounts), seedFile, cfg.Database.Driver)
 }
 
 // gracefulShutdown 按顺序关闭所有资源
-// 顺序：HTTP → WebSocket → 后台任务 → 数据库
+// 顺序：HTTP → WebSocket → 后台任务 → 数据库/Redis
 // 原则：先停止接受新请求，再等待进行中的操作完成，最后释放资源
-func gracefulShutdown(srv *http.Server) {
+func gracefulShutdown(srv *http.Server, reconciler *service.Reconciler, holdSweeper *service.HoldSweeper) {
 	// Step 1: 停止接受新 HTTP 请求，等待已有请求完成（最多30秒）
 	// 保证正在处理的扣款请求不会被强制中断，避免数据不一致
 	log.Println("[1/4] 停止 HTTP 服务器...")
@@ -87,16 +166,19 @@ func gracefulShutdown(srv *http.Server) {
 	api.CloseAllWebSockets()
 	log.Println("[2/4] WebSocket 连接已全部关闭")
 
-	// Step 3: 停止后台监控任务
+	// Step 3: 停止后台监控、对账和过期冻结释放任务
 	// 等待当前正在执行的数据库查询完成，避免连接泄漏
 	log.Println("[3/4] 停止后台监控任务...")
 	api.StopBackgroundMonitoring()
+	reconciler.Stop()
+	holdSweeper.Stop()
 	log.Println("[3/4] 后台任务已停止")
 
-	// Step 4: 关闭数据库连接池
+	// Step 4: 关闭数据库连接池和 Redis 连接
 	// 必须最后关闭，因为前面的步骤可能还需要数据库
 	log.Println("[4/4] 关闭数据库连接...")
 	config.CloseDB()
+	config.CloseRedis()
 	log.Println("[4/4] 数据库连接已关闭")
 
 	log.Println("优雅关闭完成")
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/migrations"
+)
+
+// migrateUsage migrate 子命令用法
+const migrateUsage = `用法: zero-balance-loss migrate <command>
+
+  up          执行所有未执行的迁移
+  down [N]    回滚最近 N 个迁移（默认 1）
+  status      查看迁移执行状态`
+
+// runMigrateCommand 执行 migrate 子命令，返回进程退出码
+func runMigrateCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	if cfg.Database.Driver == config.DriverMemory {
+		fmt.Println("内存存储不需要迁移")
+		return 1
+	}
+
+	config.InitDB()
+	defer config.CloseDB()
+
+	migrator, err := migrations.New(config.GetDB())
+	if err != nil {
+		log.Printf("加载迁移失败: %v", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		if err != nil {
+			log.Printf("迁移失败: %v", err)
+			return 1
+		}
+		fmt.Printf("已执行 %d 个迁移\n", len(done))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				fmt.Println(migrateUsage)
+				return 2
+			}
+		}
+		done, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Printf("回滚失败: %v", err)
+			return 1
+		}
+		fmt.Printf("已回滚 %d 个迁移\n", len(done))
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Printf("读取迁移状态失败: %v", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// migrateOnStart 启动时自动执行未执行的迁移，database.skip_migrate 为 true 时跳过
+func migrateOnStart(cfg *config.Config) {
+	if cfg.Database.SkipMigrate {
+		log.Println("已跳过自动迁移（database.skip_migrate=true）")
+		return
+	}
+	migrator, err := migrations.New(config.GetDB())
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+-- 账户表
+CREATE TABLE IF NOT EXISTS accounts (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '主键ID',
+    user_id BIGINT NOT NULL UNIQUE COMMENT '用户ID',
+    balance BIGINT NOT NULL DEFAULT 0 COMMENT '账户余额（单位：分）',
+    version BIGINT NOT NULL DEFAULT 0 COMMENT '乐观锁版本号',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='账户表';
@@ -0,0 +1,20 @@
+-- 账户流水表，只追加不修改
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '主键ID',
+    request_id VARCHAR(64) NOT NULL COMMENT '请求ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    type VARCHAR(16) NOT NULL COMMENT '流水类型',
+    amount BIGINT NOT NULL COMMENT '变更金额（单位：分）',
+    balance_before BIGINT NOT NULL COMMENT '变更前余额（单位：分）',
+    balance_after BIGINT NOT NULL COMMENT '变更后余额（单位：分）',
+    strategy VARCHAR(32) NOT NULL COMMENT '扣款策略',
+    read_start BIGINT NOT NULL DEFAULT 0 COMMENT '读取开始时间（纳秒）',
+    read_end BIGINT NOT NULL DEFAULT 0 COMMENT '读取结束时间（纳秒）',
+    compute_start BIGINT NOT NULL DEFAULT 0 COMMENT '计算开始时间（纳秒）',
+    compute_end BIGINT NOT NULL DEFAULT 0 COMMENT '计算结束时间（纳秒）',
+    write_start BIGINT NOT NULL DEFAULT 0 COMMENT '写入开始时间（纳秒）',
+    write_end BIGINT NOT NULL DEFAULT 0 COMMENT '写入结束时间（纳秒）',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_user_id_id (user_id, id),
+    INDEX idx_request_id (request_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='账户流水表';
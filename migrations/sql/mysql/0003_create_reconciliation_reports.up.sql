@@ -0,0 +1,15 @@
+-- 对账差异报告表
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '主键ID',
+    run_id VARCHAR(64) NOT NULL COMMENT '对账批次ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    expected_balance BIGINT NOT NULL COMMENT '重放流水得到的余额（单位：分）',
+    actual_balance BIGINT NOT NULL COMMENT '账户实际余额（单位：分）',
+    lost_amount BIGINT NOT NULL COMMENT '实际余额 - 理论余额（单位：分）',
+    transaction_count INT NOT NULL COMMENT '参与重放的流水条数',
+    baseline_transaction_id BIGINT NOT NULL COMMENT '重放起点的流水ID',
+    implicated_request_ids TEXT COMMENT '涉及的请求ID，逗号分隔',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_run_id (run_id),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='对账差异报告表';
@@ -0,0 +1,11 @@
+-- 幂等键表
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    idempotency_key VARCHAR(255) PRIMARY KEY COMMENT '客户端传入的 Idempotency-Key',
+    fingerprint VARCHAR(64) NOT NULL COMMENT '请求指纹',
+    completed BOOLEAN NOT NULL DEFAULT FALSE COMMENT '是否已保存最终响应',
+    status_code INT NOT NULL DEFAULT 0 COMMENT '最终响应的 HTTP 状态码',
+    response_body TEXT COMMENT '最终响应体',
+    expires_at TIMESTAMP(6) NOT NULL COMMENT '过期时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='幂等键表';
@@ -0,0 +1,5 @@
+DROP INDEX idx_accounts_status_balance ON accounts;
+
+ALTER TABLE accounts
+    DROP COLUMN closed_at,
+    DROP COLUMN status;
@@ -0,0 +1,6 @@
+-- 账户状态：active 可正常扣款，closed 已关闭，拒绝扣款和重置
+ALTER TABLE accounts
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT '账户状态' AFTER version,
+    ADD COLUMN closed_at TIMESTAMP NULL DEFAULT NULL COMMENT '关闭时间' AFTER status;
+
+CREATE INDEX idx_accounts_status_balance ON accounts (status, balance);
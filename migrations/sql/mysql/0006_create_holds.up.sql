@@ -0,0 +1,19 @@
+-- 冻结金额：可用余额 = balance - held_balance
+ALTER TABLE accounts
+    ADD COLUMN held_balance BIGINT NOT NULL DEFAULT 0 COMMENT '冻结金额（单位：分）' AFTER balance;
+
+-- 预授权冻结表
+CREATE TABLE IF NOT EXISTS holds (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT '主键ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    amount BIGINT NOT NULL COMMENT '冻结金额（单位：分）',
+    captured_amount BIGINT NOT NULL DEFAULT 0 COMMENT '实际扣款金额（单位：分）',
+    status VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT '冻结状态',
+    request_id VARCHAR(64) NOT NULL COMMENT '创建冻结的请求ID',
+    strategy VARCHAR(32) NOT NULL COMMENT '创建冻结时使用的策略',
+    expires_at TIMESTAMP(6) NOT NULL COMMENT '过期时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    INDEX idx_user_id_id (user_id, id),
+    INDEX idx_status_expires_at (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='预授权冻结表';
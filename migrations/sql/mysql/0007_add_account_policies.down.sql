@@ -0,0 +1,7 @@
+DROP INDEX idx_user_id_created_at ON transactions;
+
+ALTER TABLE accounts
+    DROP COLUMN min_balance,
+    DROP COLUMN daily_limit,
+    DROP COLUMN max_single_amount,
+    DROP COLUMN overdraft_limit;
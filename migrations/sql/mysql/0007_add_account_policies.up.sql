@@ -0,0 +1,9 @@
+-- 账户扣款策略，各项为 0 表示不限制
+ALTER TABLE accounts
+    ADD COLUMN overdraft_limit BIGINT NOT NULL DEFAULT 0 COMMENT '透支额度（单位：分）' AFTER held_balance,
+    ADD COLUMN max_single_amount BIGINT NOT NULL DEFAULT 0 COMMENT '单笔扣款上限（单位：分）' AFTER overdraft_limit,
+    ADD COLUMN daily_limit BIGINT NOT NULL DEFAULT 0 COMMENT '最近 24 小时扣款累计上限（单位：分）' AFTER max_single_amount,
+    ADD COLUMN min_balance BIGINT NOT NULL DEFAULT 0 COMMENT '扣款后可用余额下限（单位：分）' AFTER daily_limit;
+
+-- 按时间窗口汇总账户的扣款金额
+CREATE INDEX idx_user_id_created_at ON transactions (user_id, created_at);
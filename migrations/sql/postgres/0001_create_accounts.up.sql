@@ -0,0 +1,13 @@
+-- 账户表
+CREATE TABLE IF NOT EXISTS accounts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL UNIQUE,
+    balance BIGINT NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE accounts IS '账户表';
+COMMENT ON COLUMN accounts.balance IS '账户余额（单位：分）';
+COMMENT ON COLUMN accounts.version IS '乐观锁版本号';
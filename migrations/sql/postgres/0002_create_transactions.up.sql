@@ -0,0 +1,26 @@
+-- 账户流水表，只追加不修改
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL,
+    balance_before BIGINT NOT NULL,
+    balance_after BIGINT NOT NULL,
+    strategy VARCHAR(32) NOT NULL,
+    read_start BIGINT NOT NULL DEFAULT 0,
+    read_end BIGINT NOT NULL DEFAULT 0,
+    compute_start BIGINT NOT NULL DEFAULT 0,
+    compute_end BIGINT NOT NULL DEFAULT 0,
+    write_start BIGINT NOT NULL DEFAULT 0,
+    write_end BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_id ON transactions (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_request_id ON transactions (request_id);
+
+COMMENT ON TABLE transactions IS '账户流水表';
+COMMENT ON COLUMN transactions.amount IS '变更金额（单位：分）';
+COMMENT ON COLUMN transactions.balance_before IS '变更前余额（单位：分）';
+COMMENT ON COLUMN transactions.balance_after IS '变更后余额（单位：分）';
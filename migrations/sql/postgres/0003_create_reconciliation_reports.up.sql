@@ -0,0 +1,19 @@
+-- 对账差异报告表
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id BIGSERIAL PRIMARY KEY,
+    run_id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL,
+    expected_balance BIGINT NOT NULL,
+    actual_balance BIGINT NOT NULL,
+    lost_amount BIGINT NOT NULL,
+    transaction_count INTEGER NOT NULL,
+    baseline_transaction_id BIGINT NOT NULL,
+    implicated_request_ids TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_run_id ON reconciliation_reports (run_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_user_id ON reconciliation_reports (user_id);
+
+COMMENT ON TABLE reconciliation_reports IS '对账差异报告表';
+COMMENT ON COLUMN reconciliation_reports.lost_amount IS '实际余额 - 理论余额（单位：分）';
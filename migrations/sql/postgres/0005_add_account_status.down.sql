@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_accounts_status_balance;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
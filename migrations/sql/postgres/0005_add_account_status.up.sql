@@ -0,0 +1,8 @@
+-- 账户状态：active 可正常扣款，closed 已关闭，拒绝扣款和重置
+ALTER TABLE accounts ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE accounts ADD COLUMN closed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_status_balance ON accounts (status, balance);
+
+COMMENT ON COLUMN accounts.status IS '账户状态';
+COMMENT ON COLUMN accounts.closed_at IS '关闭时间';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS held_balance;
@@ -0,0 +1,25 @@
+-- 冻结金额：可用余额 = balance - held_balance
+ALTER TABLE accounts ADD COLUMN held_balance BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN accounts.held_balance IS '冻结金额（单位：分）';
+
+-- 预授权冻结表
+CREATE TABLE IF NOT EXISTS holds (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    request_id VARCHAR(64) NOT NULL,
+    strategy VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_user_id_id ON holds (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_holds_status_expires_at ON holds (status, expires_at);
+
+COMMENT ON TABLE holds IS '预授权冻结表';
+COMMENT ON COLUMN holds.amount IS '冻结金额（单位：分）';
+COMMENT ON COLUMN holds.captured_amount IS '实际扣款金额（单位：分）';
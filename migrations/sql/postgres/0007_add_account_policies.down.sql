@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_user_id_created_at;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS min_balance;
+ALTER TABLE accounts DROP COLUMN IF EXISTS daily_limit;
+ALTER TABLE accounts DROP COLUMN IF EXISTS max_single_amount;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
@@ -0,0 +1,13 @@
+-- 账户扣款策略，各项为 0 表示不限制
+ALTER TABLE accounts ADD COLUMN overdraft_limit BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN max_single_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN daily_limit BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN min_balance BIGINT NOT NULL DEFAULT 0;
+
+-- 按时间窗口汇总账户的扣款金额
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_created_at ON transactions (user_id, created_at);
+
+COMMENT ON COLUMN accounts.overdraft_limit IS '透支额度（单位：分）';
+COMMENT ON COLUMN accounts.max_single_amount IS '单笔扣款上限（单位：分）';
+COMMENT ON COLUMN accounts.daily_limit IS '最近 24 小时扣款累计上限（单位：分）';
+COMMENT ON COLUMN accounts.min_balance IS '扣款后可用余额下限（单位：分）';
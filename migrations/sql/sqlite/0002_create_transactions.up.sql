@@ -0,0 +1,21 @@
+-- 账户流水表，只追加不修改
+CREATE TABLE IF NOT EXISTS transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    balance_before INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL,
+    strategy TEXT NOT NULL,
+    read_start INTEGER NOT NULL DEFAULT 0,
+    read_end INTEGER NOT NULL DEFAULT 0,
+    compute_start INTEGER NOT NULL DEFAULT 0,
+    compute_end INTEGER NOT NULL DEFAULT 0,
+    write_start INTEGER NOT NULL DEFAULT 0,
+    write_end INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_id ON transactions (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_request_id ON transactions (request_id);
@@ -0,0 +1,16 @@
+-- 对账差异报告表
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    expected_balance INTEGER NOT NULL,
+    actual_balance INTEGER NOT NULL,
+    lost_amount INTEGER NOT NULL,
+    transaction_count INTEGER NOT NULL,
+    baseline_transaction_id INTEGER NOT NULL,
+    implicated_request_ids TEXT,
+    created_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_run_id ON reconciliation_reports (run_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_user_id ON reconciliation_reports (user_id);
@@ -0,0 +1,5 @@
+-- 账户状态：active 可正常扣款，closed 已关闭，拒绝扣款和重置
+ALTER TABLE accounts ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE accounts ADD COLUMN closed_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_status_balance ON accounts (status, balance);
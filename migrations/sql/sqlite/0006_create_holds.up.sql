@@ -0,0 +1,19 @@
+-- 冻结金额：可用余额 = balance - held_balance
+ALTER TABLE accounts ADD COLUMN held_balance INTEGER NOT NULL DEFAULT 0;
+
+-- 预授权冻结表
+CREATE TABLE IF NOT EXISTS holds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    captured_amount INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'active',
+    request_id TEXT NOT NULL,
+    strategy TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_user_id_id ON holds (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_holds_status_expires_at ON holds (status, expires_at);
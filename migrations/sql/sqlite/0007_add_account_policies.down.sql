@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_user_id_created_at;
+
+ALTER TABLE accounts DROP COLUMN min_balance;
+ALTER TABLE accounts DROP COLUMN daily_limit;
+ALTER TABLE accounts DROP COLUMN max_single_amount;
+ALTER TABLE accounts DROP COLUMN overdraft_limit;
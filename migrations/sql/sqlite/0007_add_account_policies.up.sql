@@ -0,0 +1,8 @@
+-- 账户扣款策略，各项为 0 表示不限制
+ALTER TABLE accounts ADD COLUMN overdraft_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN max_single_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN daily_limit INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN min_balance INTEGER NOT NULL DEFAULT 0;
+
+-- 按时间窗口汇总账户的扣款金额
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_created_at ON transactions (user_id, created_at);
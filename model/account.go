@@ -4,16 +4,51 @@ import (
 	"time"
 )
 
+// 账户状态
+const (
+	AccountStatusActive = "active" // 正常
+	AccountStatusClosed = "closed" // 已关闭，拒绝扣款和重置
+)
+
 // Account 账户模型
 type Account struct {
-	ID        int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	UserID    int64     `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
-	Balance   int64     `gorm:"column:balance;not null;default:0" json:"balance"` // 余额，单位：分
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID          int64         `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID      int64         `gorm:"column:user_id;not null;uniqueIndex" json:"user_id"`
+	Balance     int64         `gorm:"column:balance;not null;default:0" json:"balance"`           // 余额，单位：分
+	HeldBalance int64         `gorm:"column:held_balance;not null;default:0" json:"held_balance"` // 冻结金额，单位：分，可用余额 = 余额 - 冻结金额
+	Policy      AccountPolicy `gorm:"embedded" json:"policy"`                                     // 扣款策略
+	Version     int64         `gorm:"column:version;not null;default:0" json:"version"`           // 乐观锁版本号，每次更新 +1
+	Status      string        `gorm:"column:status;not null;default:active" json:"status"`        // 账户状态，见 AccountStatus* 常量
+	ClosedAt    *time.Time    `gorm:"column:closed_at" json:"closed_at,omitempty"`                // 关闭时间，未关闭时为空
+	CreatedAt   time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time     `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// AccountPolicy 账户扣款策略，各项为 0 表示不限制
+// 透支额度和最低余额共同决定扣款后可用余额的下限：MinBalance - OverdraftLimit，两者不能同时设置
+type AccountPolicy struct {
+	OverdraftLimit  int64 `gorm:"column:overdraft_limit;not null;default:0" json:"overdraft_limit"`     // 透支额度（分），扣款后可用余额最低可到 -OverdraftLimit
+	MaxSingleAmount int64 `gorm:"column:max_single_amount;not null;default:0" json:"max_single_amount"` // 单笔扣款上限（分）
+	DailyLimit      int64 `gorm:"column:daily_limit;not null;default:0" json:"daily_limit"`             // 最近 24 小时扣款累计上限（分），按滚动窗口计算
+	MinBalance      int64 `gorm:"column:min_balance;not null;default:0" json:"min_balance"`             // 扣款后可用余额下限（分）
+}
+
+// Floor 扣款后可用余额的下限
+func (p AccountPolicy) Floor() int64 {
+	return p.MinBalance - p.OverdraftLimit
 }
 
 // TableName 指定表名
 func (Account) TableName() string {
 	return "accounts"
 }
+
+// Closed 账户是否已关闭
+func (a *Account) Closed() bool {
+	return a.Status == AccountStatusClosed
+}
+
+// Available 可用余额，扣款和新的冻结只能使用这部分
+func (a *Account) Available() int64 {
+	return a.Balance - a.HeldBalance
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+// 冻结状态
+const (
+	HoldStatusActive   = "active"   // 冻结中，计入账户的 held_balance
+	HoldStatusCaptured = "captured" // 已扣款
+	HoldStatusVoided   = "voided"   // 已撤销
+	HoldStatusExpired  = "expired"  // 超时未扣款，由后台任务释放
+)
+
+// Hold 预授权冻结记录
+// 冻结时从可用余额中划出 Amount 计入 accounts.held_balance，余额本身不变；
+// 扣款（capture）时余额和冻结金额同时减少，撤销（void）或过期时只释放冻结金额。
+// 状态只能从 active 变为其他状态，一笔冻结只会被扣款或释放一次
+type Hold struct {
+	ID             int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID         int64     `gorm:"column:user_id;not null;index" json:"user_id"`
+	Amount         int64     `gorm:"column:amount;not null" json:"amount"`                             // 冻结金额，单位：分
+	CapturedAmount int64     `gorm:"column:captured_amount;not null;default:0" json:"captured_amount"` // 实际扣款金额，不超过冻结金额，剩余部分随扣款一起释放
+	Status         string    `gorm:"column:status;not null;default:active" json:"status"`              // 见 HoldStatus* 常量
+	RequestID      string    `gorm:"column:request_id;not null" json:"request_id"`                     // 创建冻结的请求ID
+	Strategy       string    `gorm:"column:strategy;not null" json:"strategy"`                         // 创建冻结时使用的策略
+	ExpiresAt      time.Time `gorm:"column:expires_at;not null" json:"expires_at"`                     // 过期时间，过期后不能再扣款
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Hold) TableName() string {
+	return "holds"
+}
+
+// Expired 冻结在 now 时是否已过期
+func (h *Hold) Expired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// IdempotencyKey 幂等键记录
+// 第一次请求占用 key 时 Completed 为 false，处理完成后保存最终响应；过期后 key 可以重新使用
+type IdempotencyKey struct {
+	Key          string    `gorm:"column:idempotency_key;primaryKey" json:"key"`
+	Fingerprint  string    `gorm:"column:fingerprint;not null" json:"fingerprint"` // 请求指纹（方法、路径和请求体的摘要）
+	Completed    bool      `gorm:"column:completed;not null;default:false" json:"completed"`
+	StatusCode   int       `gorm:"column:status_code;not null;default:0" json:"status_code"` // 最终响应的 HTTP 状态码
+	ResponseBody string    `gorm:"column:response_body;type:text" json:"response_body"`      // 最终响应体
+	ExpiresAt    time.Time `gorm:"column:expires_at;not null;index" json:"expires_at"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Expired 是否已过期
+func (k *IdempotencyKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReconciliationReport 对账差异报告
+// 对账时从最近一次重置开始重放账户流水得到理论余额，与 accounts.balance 不一致时记录一份报告
+type ReconciliationReport struct {
+	ID                    int64         `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	RunID                 string        `gorm:"column:run_id;not null;index" json:"run_id"` // 同一次对账产生的报告共享
+	UserID                int64         `gorm:"column:user_id;not null;index" json:"user_id"`
+	ExpectedBalance       int64         `gorm:"column:expected_balance;not null" json:"expected_balance"`               // 重放流水得到的余额（分）
+	ActualBalance         int64         `gorm:"column:actual_balance;not null" json:"actual_balance"`                   // accounts.balance（分）
+	LostAmount            int64         `gorm:"column:lost_amount;not null" json:"lost_amount"`                         // 实际余额 - 理论余额（分）
+	TransactionCount      int           `gorm:"column:transaction_count;not null" json:"transaction_count"`             // 参与重放的流水条数
+	BaselineTransactionID int64         `gorm:"column:baseline_transaction_id;not null" json:"baseline_transaction_id"` // 重放起点的流水 ID
+	ImplicatedRequestIDs  RequestIDList `gorm:"column:implicated_request_ids;type:text" json:"implicated_request_ids"`  // 读到过期余额或被覆盖的请求
+	CreatedAt             time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (ReconciliationReport) TableName() string {
+	return "reconciliation_reports"
+}
+
+// RequestIDList 请求ID列表，数据库中以逗号分隔的字符串保存
+type RequestIDList []string
+
+// Value 实现 driver.Valuer
+func (l RequestIDList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+// Scan 实现 sql.Scanner
+func (l *RequestIDList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RequestIDList", src)
+	}
+
+	*l = RequestIDList{}
+	if s != "" {
+		*l = strings.Split(s, ",")
+	}
+	return nil
+}
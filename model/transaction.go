@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+)
+
+// 流水类型
+const (
+	TransactionTypeDeduct      = "deduct"       // 扣款
+	TransactionTypeCredit      = "credit"       // 入账
+	TransactionTypeTransferOut = "transfer_out" // 转账转出
+	TransactionTypeTransferIn  = "transfer_in"  // 转账转入
+	TransactionTypeCapture     = "capture"      // 预授权扣款，冻结和撤销不改变余额，不写流水
+	TransactionTypeReset       = "reset"        // 重置余额，对账时作为重放的起点
+)
+
+// Transaction 账户流水，只追加不修改
+// 每次成功的余额变更写入一行，记录变更前后的余额和所用策略，
+// 用于事后核对哪些扣款被覆盖（Lost Update）
+type Transaction struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	RequestID     string `gorm:"column:request_id;not null;index" json:"request_id"`
+	UserID        int64  `gorm:"column:user_id;not null;index" json:"user_id"`
+	Type          string `gorm:"column:type;not null" json:"type"`
+	Amount        int64  `gorm:"column:amount;not null" json:"amount"`                 // 变更金额，单位：分，始终为正数，方向由 Type 决定（重置流水为 0）
+	BalanceBefore int64  `gorm:"column:balance_before;not null" json:"balance_before"` // 变更前余额（本请求读取到的值）
+	BalanceAfter  int64  `gorm:"column:balance_after;not null" json:"balance_after"`   // 变更后余额（本请求写入的值）
+	Strategy      string `gorm:"column:strategy;not null" json:"strategy"`
+
+	// 时间线（纳秒），取自 service.Timeline
+	ReadStart    int64 `gorm:"column:read_start;not null;default:0" json:"read_start"`
+	ReadEnd      int64 `gorm:"column:read_end;not null;default:0" json:"read_end"`
+	ComputeStart int64 `gorm:"column:compute_start;not null;default:0" json:"compute_start"`
+	ComputeEnd   int64 `gorm:"column:compute_end;not null;default:0" json:"compute_end"`
+	WriteStart   int64 `gorm:"column:write_start;not null;default:0" json:"write_start"`
+	WriteEnd     int64 `gorm:"column:write_end;not null;default:0" json:"write_end"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Transaction) TableName() string {
+	return "transactions"
+}
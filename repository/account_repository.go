@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"zero-balance-loss/model"
+)
+
+var (
+	// ErrAccountNotFound 账户不存在
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrAccountExists 创建账户时 user_id 已存在
+	ErrAccountExists = errors.New("account already exists")
+	// ErrHoldNotFound 冻结记录不存在
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrLockTimeout 等待锁超时（行锁或命名锁）
+	ErrLockTimeout = errors.New("lock wait timeout")
+	// ErrDeadlock 数据库检测到死锁并回滚了当前事务
+	ErrDeadlock = errors.New("deadlock detected")
+	// ErrSerializationFailure 事务因并发修改无法串行化，需要整体重试（PostgreSQL 40001）
+	ErrSerializationFailure = errors.New("serialization failure")
+)
+
+// TxOptions 事务选项
+type TxOptions struct {
+	Isolation       sql.IsolationLevel // 隔离级别，sql.LevelDefault 表示使用数据库默认值
+	LockWaitTimeout time.Duration      // 锁等待超时（含行锁和事务级命名锁），0 表示使用数据库默认值
+}
+
+// AccountFilter 账户查询条件，结果按 user_id 升序
+type AccountFilter struct {
+	AfterUserID int64  // 只返回 user_id 大于该值的账户，用作分页游标
+	MinBalance  *int64 // 余额下限（含），nil 表示不限
+	MaxBalance  *int64 // 余额上限（含），nil 表示不限
+	Status      string // 账户状态，空表示不限
+	Limit       int
+}
+
+// HeldAdjustment 按增量修改余额和冻结金额，用于冻结、扣款和释放
+type HeldAdjustment struct {
+	Balance          int64  // 余额增量
+	Held             int64  // 冻结金额增量
+	ExpectedVersion  *int64 // 不为 nil 时只在版本号相等时修改（乐观锁）
+	RequireAvailable bool   // 为 true 时只在修改后可用余额（余额 - 冻结金额）不低于 min_balance - overdraft_limit 时修改（原子条件更新）
+}
+
+// HoldFilter 冻结记录查询条件，结果按 ID 升序
+type HoldFilter struct {
+	UserID        int64      // 账户，0 表示不限
+	Status        string     // 冻结状态，空表示不限
+	ExpiresBefore *time.Time // 只返回过期时间不晚于该时刻的记录，nil 表示不限
+	AfterID       int64      // 只返回 ID 大于该值的记录，用作分页游标
+	Limit         int
+}
+
+// AccountRepository 账户存储
+// 抽象出各种扣款策略需要的全部存储操作，AccountService 不再直接依赖全局 config.DB。
+// 在 Transaction 回调中拿到的是绑定该事务的实例，其上的所有操作都属于同一事务。
+// 死锁、锁等待超时等后端特有错误统一转换为本包的哨兵错误
+type AccountRepository interface {
+	// GetAccount 普通读取，账户不存在返回 ErrAccountNotFound
+	GetAccount(ctx context.Context, userID int64) (*model.Account, error)
+
+	// ListAccounts 按 user_id 升序列出全部账户
+	ListAccounts(ctx context.Context) ([]model.Account, error)
+
+	// FindAccounts 按条件分页查询账户
+	FindAccounts(ctx context.Context, filter AccountFilter) ([]model.Account, error)
+
+	// CreateAccount 创建账户并回填 ID，user_id 已存在返回 ErrAccountExists
+	CreateAccount(ctx context.Context, account *model.Account) error
+
+	// UpdateAccountStatus 修改账户状态并递增版本号，关闭时记录关闭时间，账户不存在返回 ErrAccountNotFound
+	UpdateAccountStatus(ctx context.Context, userID int64, status string) error
+
+	// GetAccountForUpdate 加排他锁读取（SELECT ... FOR UPDATE），锁持有到事务结束，
+	// 只在 Transaction 内调用才有意义
+	GetAccountForUpdate(ctx context.Context, userID int64) (*model.Account, error)
+
+	// UpdateBalance 无条件写入余额并递增版本号，账户不存在返回 ErrAccountNotFound
+	UpdateBalance(ctx context.Context, userID int64, balance int64) error
+
+	// CompareAndSwapBalance 版本号等于 expectedVersion 时写入余额并递增版本号，返回是否写入
+	CompareAndSwapBalance(ctx context.Context, userID int64, expectedVersion int64, balance int64) (bool, error)
+
+	// DecrementBalanceWithinPolicy 按账户策略原子扣减并递增版本号，返回是否扣减：
+	// 账户未关闭，扣减后可用余额不低于 min_balance - overdraft_limit，且设置了单笔上限时 amount 不超过上限。
+	// 最近 24 小时的累计上限需要汇总流水，不在这条语句内检查
+	DecrementBalanceWithinPolicy(ctx context.Context, userID int64, amount int64) (bool, error)
+
+	// IncrementBalance 原子增加余额并递增版本号，账户不存在返回 ErrAccountNotFound
+	IncrementBalance(ctx context.Context, userID int64, amount int64) error
+
+	// AdjustHeldBalance 按增量修改余额和冻结金额并递增版本号，返回是否修改；
+	// 没有附加条件时账户不存在返回 ErrAccountNotFound，有条件时账户不存在只是返回 false
+	AdjustHeldBalance(ctx context.Context, userID int64, adj HeldAdjustment) (bool, error)
+
+	// UpdateAccountPolicy 修改账户扣款策略并递增版本号，账户不存在返回 ErrAccountNotFound
+	UpdateAccountPolicy(ctx context.Context, userID int64, policy model.AccountPolicy) error
+
+	// CreateHold 创建冻结记录并回填 ID
+	CreateHold(ctx context.Context, hold *model.Hold) error
+
+	// GetHold 读取冻结记录，不存在返回 ErrHoldNotFound
+	GetHold(ctx context.Context, id int64) (*model.Hold, error)
+
+	// FindHolds 按条件分页查询冻结记录
+	FindHolds(ctx context.Context, filter HoldFilter) ([]model.Hold, error)
+
+	// FinishHold 状态为 active 时把冻结改为 status 并记录扣款金额，返回是否修改；
+	// 并发的扣款、撤销和过期只有一个能成功
+	FinishHold(ctx context.Context, id int64, status string, capturedAmount int64) (bool, error)
+
+	// Transaction 在事务内执行 fn，fn 返回错误时回滚，opts 为 nil 时使用默认选项
+	Transaction(ctx context.Context, opts *TxOptions, fn func(repo AccountRepository) error) error
+
+	// WithAdvisoryLock 持有命名锁执行 fn，timeout 内未获得锁返回 ErrLockTimeout
+	WithAdvisoryLock(ctx context.Context, name string, timeout time.Duration, fn func() error) error
+
+	// AppendTransaction 追加一条流水并回填 ID，在 Transaction 内调用时与余额写入一起提交或回滚
+	AppendTransaction(ctx context.Context, txn *model.Transaction) error
+
+	// SumTransactions 汇总账户 since 之后（含）指定类型流水的金额，在 Transaction 内调用时包含本事务追加的流水
+	SumTransactions(ctx context.Context, userID int64, types []string, since time.Time) (int64, error)
+
+	// ListTransactions 按 ID 从新到旧列出账户流水，只返回 ID 小于 beforeID 的记录，beforeID 为 0 表示从最新开始
+	ListTransactions(ctx context.Context, userID int64, beforeID int64, limit int) ([]model.Transaction, error)
+}
+
+// AccountSeeder 支持写入初始账户的存储，已存在的账户保持不变
+type AccountSeeder interface {
+	Seed(ctx context.Context, accounts []model.Account) error
+}
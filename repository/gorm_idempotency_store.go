@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"zero-balance-loss/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormIdempotencyStore 基于数据库的幂等键存储，多个服务实例共享同一个数据库时同样有效
+// Reserve 依赖主键冲突保证原子性：INSERT ... ON CONFLICT DO NOTHING 只有一个请求能插入成功。
+// 时间统一以 UTC 写入和比较，避免 SQLite 按字符串比较时间时受时区影响
+type GormIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewGormIdempotencyStore 创建数据库幂等键存储
+func NewGormIdempotencyStore(db *gorm.DB) *GormIdempotencyStore {
+	return &GormIdempotencyStore{db: db}
+}
+
+// Reserve 占用 key
+func (s *GormIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error) {
+	db := s.db.WithContext(ctx)
+
+	// 已有记录在插入和读取之间过期被删除时重试一次
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now().UTC()
+
+		// 过期的记录不再有效，先删掉才能重新占用
+		if err := db.Where("idempotency_key = ? AND expires_at <= ?", key, now).Delete(&model.IdempotencyKey{}).Error; err != nil {
+			return nil, translateError(err)
+		}
+
+		record := model.IdempotencyKey{
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(ttl),
+			CreatedAt:   now,
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return nil, translateError(result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		existing, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+	return nil, errors.New("failed to reserve idempotency key: concurrent expiry")
+}
+
+// Get 读取 key 的记录
+func (s *GormIdempotencyStore) Get(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	var record model.IdempotencyKey
+	err := s.db.WithContext(ctx).Where("idempotency_key = ?", key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if record.Expired(time.Now()) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Complete 保存最终响应
+func (s *GormIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, body []byte, ttl time.Duration) error {
+	err := s.db.WithContext(ctx).Model(&model.IdempotencyKey{}).
+		Where("idempotency_key = ?", key).
+		Updates(map[string]interface{}{
+			"completed":     true,
+			"status_code":   statusCode,
+			"response_body": string(body),
+			"expires_at":    time.Now().UTC().Add(ttl),
+		}).Error
+	return translateError(err)
+}
+
+// Release 删除 key 的记录
+func (s *GormIdempotencyStore) Release(ctx context.Context, key string) error {
+	err := s.db.WithContext(ctx).Where("idempotency_key = ?", key).Delete(&model.IdempotencyKey{}).Error
+	return translateError(err)
+}
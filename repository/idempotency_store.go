@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zero-balance-loss/model"
+)
+
+// IdempotencyStore 幂等键存储
+// 实现必须保证 Reserve 是原子的：同一个 key 并发 Reserve 时只有一个调用方占用成功
+type IdempotencyStore interface {
+	// Reserve 占用 key 并记录请求指纹，ttl 后过期。
+	// 占用成功返回 (nil, nil)；key 已存在且未过期时返回已有记录（可能仍在处理中）
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error)
+
+	// Get 读取 key 的记录，不存在或已过期返回 (nil, nil)
+	Get(ctx context.Context, key string) (*model.IdempotencyKey, error)
+
+	// Complete 保存最终响应，过期时间从现在起重新计算
+	Complete(ctx context.Context, key string, statusCode int, body []byte, ttl time.Duration) error
+
+	// Release 删除 key 的记录，之后同一个 key 可以重新执行
+	Release(ctx context.Context, key string) error
+}
+
+// memoryIdempotencySweepInterval 内存幂等键存储清理过期记录的最小间隔
+const memoryIdempotencySweepInterval = time.Minute
+
+// MemoryIdempotencyStore 进程内幂等键存储，只在单个进程内有效
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	keys      map[string]model.IdempotencyKey
+	lastSweep time.Time
+}
+
+// NewMemoryIdempotencyStore 创建进程内幂等键存储
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		keys:      make(map[string]model.IdempotencyKey),
+		lastSweep: time.Now(),
+	}
+}
+
+// Reserve 占用 key
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	if existing, ok := s.keys[key]; ok && !existing.Expired(now) {
+		return &existing, nil
+	}
+	s.keys[key] = model.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+	return nil, nil
+}
+
+// Get 读取 key 的记录
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.keys[key]
+	if !ok || existing.Expired(time.Now()) {
+		return nil, nil
+	}
+	return &existing, nil
+}
+
+// Complete 保存最终响应
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, body []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.keys[key]
+	if !ok {
+		return nil
+	}
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseBody = string(body)
+	record.ExpiresAt = time.Now().Add(ttl)
+	s.keys[key] = record
+	return nil
+}
+
+// Release 删除 key 的记录
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+// sweep 清理过期记录，调用方需持有 mu；距上次清理不足 memoryIdempotencySweepInterval 时跳过
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryIdempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, record := range s.keys {
+		if record.Expired(now) {
+			delete(s.keys, key)
+		}
+	}
+}
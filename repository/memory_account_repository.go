@@ -0,0 +1,681 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"zero-balance-loss/model"
+)
+
+// defaultMemoryLockWaitTimeout 未指定 TxOptions.LockWaitTimeout 时的行锁等待超时，与 InnoDB 默认值一致
+const defaultMemoryLockWaitTimeout = 50 * time.Second
+
+// MemoryOptions 内存存储参数
+type MemoryOptions struct {
+	ReadLatency  time.Duration // 每次读取的模拟延迟
+	WriteLatency time.Duration // 每次写入的模拟延迟
+}
+
+// MemoryAccountRepository 进程内账户存储，用于演示和 CI，不依赖任何外部服务
+// 行为尽量贴近 InnoDB：
+//   - 普通读取不加锁，只能看到已提交的数据；
+//   - 每次写入（包括事务外的单条写入）都持有行锁，事务内的行锁持有到提交或回滚；
+//   - 事务内的写入先缓存，提交时一次性生效，回滚时直接丢弃。
+//
+// 因此不加锁的"读取-计算-写入"在这里和在 MySQL 上一样会丢失更新。
+// SERIALIZABLE 事务的普通读取按加锁读处理（InnoDB 是共享锁，这里简化为排他锁），不会产生死锁
+type MemoryAccountRepository struct {
+	store *memoryStore
+	tx    *memoryTx // 非 nil 表示绑定到某个事务
+}
+
+// memoryStore 所有 MemoryAccountRepository 实例共享的数据
+type memoryStore struct {
+	opts MemoryOptions
+
+	mu       sync.RWMutex
+	accounts map[int64]model.Account
+	nextID   int64
+
+	txns      []model.Transaction // 流水，按 ID 升序
+	nextTxnID int64
+
+	reports      []model.ReconciliationReport // 对账报告，按 ID 升序
+	nextReportID int64
+
+	holds      map[int64]model.Hold // 冻结记录
+	nextHoldID int64
+
+	rowLocksMu sync.Mutex
+	rowLocks   map[int64]chan struct{} // 行锁，容量为 1 的信号量
+	namedLocks *namedLocks             // 命名锁（对应 GET_LOCK）
+}
+
+// memoryTx 事务状态
+type memoryTx struct {
+	lockWaitTimeout time.Duration
+	serializable    bool
+	held            map[int64]bool          // 本事务持有的行锁
+	pending         map[int64]model.Account // 本事务尚未提交的写入
+	pendingTxns     []*model.Transaction    // 本事务尚未提交的流水
+	pendingHolds    map[int64]model.Hold    // 本事务尚未提交的冻结记录
+}
+
+// NewMemoryAccountRepository 创建内存账户存储
+func NewMemoryAccountRepository(opts MemoryOptions) *MemoryAccountRepository {
+	return &MemoryAccountRepository{
+		store: &memoryStore{
+			opts:       opts,
+			accounts:   make(map[int64]model.Account),
+			holds:      make(map[int64]model.Hold),
+			rowLocks:   make(map[int64]chan struct{}),
+			namedLocks: newNamedLocks(),
+		},
+	}
+}
+
+// Seed 写入初始账户，已存在的账户保持不变
+func (r *MemoryAccountRepository) Seed(ctx context.Context, accounts []model.Account) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for _, a := range accounts {
+		if _, ok := r.store.accounts[a.UserID]; ok {
+			continue
+		}
+		r.store.nextID++
+		a.ID = r.store.nextID
+		if a.Status == "" {
+			a.Status = model.AccountStatusActive
+		}
+		a.CreatedAt = now
+		a.UpdatedAt = now
+		r.store.accounts[a.UserID] = a
+	}
+	return nil
+}
+
+// GetAccount 普通读取，SERIALIZABLE 事务内按加锁读处理
+func (r *MemoryAccountRepository) GetAccount(ctx context.Context, userID int64) (*model.Account, error) {
+	if r.tx != nil && r.tx.serializable {
+		return r.GetAccountForUpdate(ctx, userID)
+	}
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+	return r.current(userID)
+}
+
+// ListAccounts 按 user_id 升序列出全部已提交的账户
+func (r *MemoryAccountRepository) ListAccounts(ctx context.Context) ([]model.Account, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	accounts := make([]model.Account, 0, len(r.store.accounts))
+	for _, a := range r.store.accounts {
+		accounts = append(accounts, a)
+	}
+	r.store.mu.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].UserID < accounts[j].UserID })
+	return accounts, nil
+}
+
+// FindAccounts 按条件分页查询已提交的账户
+func (r *MemoryAccountRepository) FindAccounts(ctx context.Context, filter AccountFilter) ([]model.Account, error) {
+	all, err := r.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []model.Account
+	for _, a := range all {
+		if len(accounts) >= filter.Limit {
+			break
+		}
+		if a.UserID <= filter.AfterUserID ||
+			(filter.MinBalance != nil && a.Balance < *filter.MinBalance) ||
+			(filter.MaxBalance != nil && a.Balance > *filter.MaxBalance) ||
+			(filter.Status != "" && a.Status != filter.Status) {
+			continue
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+// CreateAccount 创建账户，持有新账户的行锁检查 user_id 是否已存在
+// 事务内创建的账户在提交时才对其他读取可见，ID 在创建时分配，回滚后不会复用
+func (r *MemoryAccountRepository) CreateAccount(ctx context.Context, account *model.Account) error {
+	release, err := r.lockRow(ctx, account.UserID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return err
+	}
+	if _, err := r.current(account.UserID); err == nil {
+		return ErrAccountExists
+	}
+
+	now := time.Now()
+	r.store.mu.Lock()
+	r.store.nextID++
+	account.ID = r.store.nextID
+	if account.Status == "" {
+		account.Status = model.AccountStatusActive
+	}
+	account.CreatedAt = now
+	account.UpdatedAt = now
+	if r.tx == nil {
+		r.store.accounts[account.UserID] = *account
+	}
+	r.store.mu.Unlock()
+
+	if r.tx != nil {
+		r.tx.pending[account.UserID] = *account
+	}
+	return nil
+}
+
+// UpdateAccountStatus 修改账户状态
+func (r *MemoryAccountRepository) UpdateAccountStatus(ctx context.Context, userID int64, status string) error {
+	_, err := r.write(ctx, userID, func(a *model.Account) bool {
+		a.Status = status
+		a.ClosedAt = nil
+		if status == model.AccountStatusClosed {
+			now := time.Now()
+			a.ClosedAt = &now
+		}
+		return true
+	})
+	return err
+}
+
+// UpdateAccountPolicy 修改账户扣款策略
+func (r *MemoryAccountRepository) UpdateAccountPolicy(ctx context.Context, userID int64, policy model.AccountPolicy) error {
+	_, err := r.write(ctx, userID, func(a *model.Account) bool {
+		a.Policy = policy
+		return true
+	})
+	return err
+}
+
+// GetAccountForUpdate 加排他锁读取，事务外调用时读完立即释放
+func (r *MemoryAccountRepository) GetAccountForUpdate(ctx context.Context, userID int64) (*model.Account, error) {
+	release, err := r.lockRow(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+	return r.current(userID)
+}
+
+// UpdateBalance 无条件写入余额
+func (r *MemoryAccountRepository) UpdateBalance(ctx context.Context, userID int64, balance int64) error {
+	_, err := r.write(ctx, userID, func(a *model.Account) bool {
+		a.Balance = balance
+		return true
+	})
+	return err
+}
+
+// CompareAndSwapBalance 版本号匹配时写入余额
+func (r *MemoryAccountRepository) CompareAndSwapBalance(ctx context.Context, userID int64, expectedVersion int64, balance int64) (bool, error) {
+	swapped, err := r.write(ctx, userID, func(a *model.Account) bool {
+		if a.Version != expectedVersion {
+			return false
+		}
+		a.Balance = balance
+		return true
+	})
+	if err == ErrAccountNotFound {
+		// 与 UPDATE ... WHERE 一致：账户不存在只是影响行数为 0
+		return false, nil
+	}
+	return swapped, err
+}
+
+// DecrementBalanceWithinPolicy 按账户策略原子扣减
+func (r *MemoryAccountRepository) DecrementBalanceWithinPolicy(ctx context.Context, userID int64, amount int64) (bool, error) {
+	deducted, err := r.write(ctx, userID, func(a *model.Account) bool {
+		if a.Closed() || a.Available()-amount < a.Policy.Floor() {
+			return false
+		}
+		if a.Policy.MaxSingleAmount > 0 && amount > a.Policy.MaxSingleAmount {
+			return false
+		}
+		a.Balance -= amount
+		return true
+	})
+	if err == ErrAccountNotFound {
+		return false, nil
+	}
+	return deducted, err
+}
+
+// IncrementBalance 原子增加余额
+func (r *MemoryAccountRepository) IncrementBalance(ctx context.Context, userID int64, amount int64) error {
+	_, err := r.write(ctx, userID, func(a *model.Account) bool {
+		a.Balance += amount
+		return true
+	})
+	return err
+}
+
+// AdjustHeldBalance 按增量修改余额和冻结金额
+func (r *MemoryAccountRepository) AdjustHeldBalance(ctx context.Context, userID int64, adj HeldAdjustment) (bool, error) {
+	adjusted, err := r.write(ctx, userID, func(a *model.Account) bool {
+		if adj.ExpectedVersion != nil && a.Version != *adj.ExpectedVersion {
+			return false
+		}
+		if adj.RequireAvailable && a.Available()+adj.Balance-adj.Held < a.Policy.Floor() {
+			return false
+		}
+		a.Balance += adj.Balance
+		a.HeldBalance += adj.Held
+		return true
+	})
+	if err == ErrAccountNotFound && (adj.ExpectedVersion != nil || adj.RequireAvailable) {
+		return false, nil
+	}
+	return adjusted, err
+}
+
+// CreateHold 创建冻结记录，事务内创建的记录在提交时才对其他读取可见
+func (r *MemoryAccountRepository) CreateHold(ctx context.Context, hold *model.Hold) error {
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.store.mu.Lock()
+	r.store.nextHoldID++
+	hold.ID = r.store.nextHoldID
+	if hold.Status == "" {
+		hold.Status = model.HoldStatusActive
+	}
+	hold.CreatedAt = now
+	hold.UpdatedAt = now
+	if r.tx == nil {
+		r.store.holds[hold.ID] = *hold
+	}
+	r.store.mu.Unlock()
+
+	if r.tx != nil {
+		r.tx.pendingHolds[hold.ID] = *hold
+	}
+	return nil
+}
+
+// GetHold 读取冻结记录
+func (r *MemoryAccountRepository) GetHold(ctx context.Context, id int64) (*model.Hold, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+	return r.currentHold(id)
+}
+
+// FindHolds 按条件分页查询已提交的冻结记录
+func (r *MemoryAccountRepository) FindHolds(ctx context.Context, filter HoldFilter) ([]model.Hold, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	var holds []model.Hold
+	for _, h := range r.store.holds {
+		if h.ID <= filter.AfterID ||
+			(filter.UserID != 0 && h.UserID != filter.UserID) ||
+			(filter.Status != "" && h.Status != filter.Status) ||
+			(filter.ExpiresBefore != nil && h.ExpiresAt.After(*filter.ExpiresBefore)) {
+			continue
+		}
+		holds = append(holds, h)
+	}
+	r.store.mu.RUnlock()
+
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ID < holds[j].ID })
+	if len(holds) > filter.Limit {
+		holds = holds[:filter.Limit]
+	}
+	return holds, nil
+}
+
+// FinishHold 冻结仍为 active 时修改状态
+// 冻结记录没有单独的行锁，这里借用所属账户的行锁，效果与 UPDATE ... WHERE status = 'active' 相同
+func (r *MemoryAccountRepository) FinishHold(ctx context.Context, id int64, status string, capturedAmount int64) (bool, error) {
+	hold, err := r.currentHold(id)
+	if err == ErrHoldNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	release, err := r.lockRow(ctx, hold.UserID)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return false, err
+	}
+
+	// 持有行锁后重新读取，期间可能已被其他请求修改
+	hold, err = r.currentHold(id)
+	if err != nil {
+		return false, err
+	}
+	if hold.Status != model.HoldStatusActive {
+		return false, nil
+	}
+	hold.Status = status
+	hold.CapturedAmount = capturedAmount
+	hold.UpdatedAt = time.Now()
+
+	if r.tx != nil {
+		r.tx.pendingHolds[id] = *hold
+		return true, nil
+	}
+	r.store.mu.Lock()
+	r.store.holds[id] = *hold
+	r.store.mu.Unlock()
+	return true, nil
+}
+
+// Transaction 在事务内执行 fn，fn 返回错误时丢弃全部写入
+// 已经在事务内时直接复用当前事务
+func (r *MemoryAccountRepository) Transaction(ctx context.Context, opts *TxOptions, fn func(repo AccountRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx := &memoryTx{
+		lockWaitTimeout: defaultMemoryLockWaitTimeout,
+		held:            make(map[int64]bool),
+		pending:         make(map[int64]model.Account),
+		pendingHolds:    make(map[int64]model.Hold),
+	}
+	if opts != nil {
+		if opts.LockWaitTimeout > 0 {
+			tx.lockWaitTimeout = opts.LockWaitTimeout
+		}
+		tx.serializable = opts.Isolation == sql.LevelSerializable
+	}
+
+	// fn panic 时同样要释放行锁，否则其他事务只能等到锁等待超时；未提交的写入随 tx 一起丢弃
+	defer func() {
+		for userID := range tx.held {
+			<-r.store.rowLock(userID)
+		}
+	}()
+
+	err := fn(&MemoryAccountRepository{store: r.store, tx: tx})
+	if err == nil {
+		r.store.mu.Lock()
+		for userID, a := range tx.pending {
+			r.store.accounts[userID] = a
+		}
+		for _, txn := range tx.pendingTxns {
+			r.store.appendTxn(txn)
+		}
+		for id, h := range tx.pendingHolds {
+			r.store.holds[id] = h
+		}
+		r.store.mu.Unlock()
+	}
+	return err
+}
+
+// WithAdvisoryLock 持有命名锁执行 fn
+func (r *MemoryAccountRepository) WithAdvisoryLock(ctx context.Context, name string, timeout time.Duration, fn func() error) error {
+	return r.store.namedLocks.with(ctx, name, timeout, fn)
+}
+
+// AppendTransaction 追加一条流水，事务内追加的流水在提交时才分配 ID 并可见
+func (r *MemoryAccountRepository) AppendTransaction(ctx context.Context, txn *model.Transaction) error {
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return err
+	}
+	if r.tx != nil {
+		r.tx.pendingTxns = append(r.tx.pendingTxns, txn)
+		return nil
+	}
+	r.store.mu.Lock()
+	r.store.appendTxn(txn)
+	r.store.mu.Unlock()
+	return nil
+}
+
+// ListTransactions 按 ID 从新到旧列出账户流水
+func (r *MemoryAccountRepository) ListTransactions(ctx context.Context, userID int64, beforeID int64, limit int) ([]model.Transaction, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var txns []model.Transaction
+	for i := len(r.store.txns) - 1; i >= 0 && len(txns) < limit; i-- {
+		txn := r.store.txns[i]
+		if txn.UserID != userID || (beforeID > 0 && txn.ID >= beforeID) {
+			continue
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+// SumTransactions 汇总时间窗口内指定类型流水的金额，事务内尚未提交的流水按当前时间计入
+func (r *MemoryAccountRepository) SumTransactions(ctx context.Context, userID int64, types []string, since time.Time) (int64, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return 0, err
+	}
+
+	match := func(txn *model.Transaction) bool {
+		return txn.UserID == userID && slices.Contains(types, txn.Type)
+	}
+
+	var total int64
+	if r.tx != nil {
+		for _, txn := range r.tx.pendingTxns {
+			if match(txn) {
+				total += txn.Amount
+			}
+		}
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// 流水在提交时按 ID 升序追加并记录创建时间，从新到旧扫描到窗口之外即可停止
+	for i := len(r.store.txns) - 1; i >= 0; i-- {
+		txn := &r.store.txns[i]
+		if txn.CreatedAt.Before(since) {
+			break
+		}
+		if match(txn) {
+			total += txn.Amount
+		}
+	}
+	return total, nil
+}
+
+// SaveReconciliationReports 批量写入对账报告
+func (r *MemoryAccountRepository) SaveReconciliationReports(ctx context.Context, reports []model.ReconciliationReport) error {
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for i := range reports {
+		r.store.nextReportID++
+		reports[i].ID = r.store.nextReportID
+		reports[i].CreatedAt = now
+		r.store.reports = append(r.store.reports, reports[i])
+	}
+	return nil
+}
+
+// ListReconciliationReports 按 ID 从新到旧列出对账报告
+func (r *MemoryAccountRepository) ListReconciliationReports(ctx context.Context, beforeID int64, limit int) ([]model.ReconciliationReport, error) {
+	if err := sleepContext(ctx, r.store.opts.ReadLatency); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var reports []model.ReconciliationReport
+	for i := len(r.store.reports) - 1; i >= 0 && len(reports) < limit; i-- {
+		if beforeID > 0 && r.store.reports[i].ID >= beforeID {
+			continue
+		}
+		reports = append(reports, r.store.reports[i])
+	}
+	return reports, nil
+}
+
+// current 读取当前可见的账户：本事务未提交的写入优先，其次是已提交的数据
+func (r *MemoryAccountRepository) current(userID int64) (*model.Account, error) {
+	if r.tx != nil {
+		if a, ok := r.tx.pending[userID]; ok {
+			return &a, nil
+		}
+	}
+
+	r.store.mu.RLock()
+	a, ok := r.store.accounts[userID]
+	r.store.mu.RUnlock()
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	return &a, nil
+}
+
+// currentHold 读取当前可见的冻结记录：本事务未提交的写入优先，其次是已提交的数据
+func (r *MemoryAccountRepository) currentHold(id int64) (*model.Hold, error) {
+	if r.tx != nil {
+		if h, ok := r.tx.pendingHolds[id]; ok {
+			return &h, nil
+		}
+	}
+
+	r.store.mu.RLock()
+	h, ok := r.store.holds[id]
+	r.store.mu.RUnlock()
+	if !ok {
+		return nil, ErrHoldNotFound
+	}
+	return &h, nil
+}
+
+// write 持有行锁修改账户，mutate 返回 false 表示条件不满足、不写入
+func (r *MemoryAccountRepository) write(ctx context.Context, userID int64, mutate func(a *model.Account) bool) (bool, error) {
+	release, err := r.lockRow(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if err := sleepContext(ctx, r.store.opts.WriteLatency); err != nil {
+		return false, err
+	}
+
+	account, err := r.current(userID)
+	if err != nil {
+		return false, err
+	}
+	if !mutate(account) {
+		return false, nil
+	}
+	account.Version++
+	account.UpdatedAt = time.Now()
+
+	if r.tx != nil {
+		r.tx.pending[userID] = *account
+		return true, nil
+	}
+	r.store.mu.Lock()
+	r.store.accounts[userID] = *account
+	r.store.mu.Unlock()
+	return true, nil
+}
+
+// lockRow 获取行锁
+// 事务内获取的行锁持有到事务结束，返回的 release 为空操作；事务外由调用方通过 release 立即释放
+func (r *MemoryAccountRepository) lockRow(ctx context.Context, userID int64) (func(), error) {
+	lock := r.store.rowLock(userID)
+
+	if r.tx == nil {
+		if err := acquireLock(ctx, lock, defaultMemoryLockWaitTimeout); err != nil {
+			return nil, err
+		}
+		return func() { <-lock }, nil
+	}
+
+	if !r.tx.held[userID] {
+		if err := acquireLock(ctx, lock, r.tx.lockWaitTimeout); err != nil {
+			return nil, err
+		}
+		r.tx.held[userID] = true
+	}
+	return func() {}, nil
+}
+
+// appendTxn 分配 ID 并写入流水，调用方需持有 mu
+func (s *memoryStore) appendTxn(txn *model.Transaction) {
+	s.nextTxnID++
+	txn.ID = s.nextTxnID
+	if txn.CreatedAt.IsZero() {
+		txn.CreatedAt = time.Now()
+	}
+	s.txns = append(s.txns, *txn)
+}
+
+// rowLock 获取账户对应的行锁，首次使用时创建
+func (s *memoryStore) rowLock(userID int64) chan struct{} {
+	s.rowLocksMu.Lock()
+	defer s.rowLocksMu.Unlock()
+
+	lock, ok := s.rowLocks[userID]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.rowLocks[userID] = lock
+	}
+	return lock
+}
+
+// sleepContext 模拟存储延迟，context 取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zero-balance-loss/model"
+)
+
+// newTestMemoryRepository 创建内存存储并写入一个账户
+func newTestMemoryRepository(t *testing.T, opts MemoryOptions, userID, balance int64) *MemoryAccountRepository {
+	t.Helper()
+	repo := NewMemoryAccountRepository(opts)
+	if err := repo.Seed(context.Background(), []model.Account{{UserID: userID, Balance: balance}}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return repo
+}
+
+// balanceOf 读取已提交的余额
+func balanceOf(t *testing.T, repo *MemoryAccountRepository, userID int64) int64 {
+	t.Helper()
+	account, err := repo.GetAccount(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	return account.Balance
+}
+
+// lockedWithin 在 timeout 内尝试获取行锁，超时返回 ErrLockTimeout
+func lockedWithin(repo *MemoryAccountRepository, userID int64, timeout time.Duration) error {
+	ctx := context.Background()
+	return repo.Transaction(ctx, &TxOptions{LockWaitTimeout: timeout}, func(tx AccountRepository) error {
+		_, err := tx.GetAccountForUpdate(ctx, userID)
+		return err
+	})
+}
+
+func TestMemoryUnlockedReadModifyWriteLosesUpdates(t *testing.T) {
+	const (
+		userID  int64 = 1
+		initial int64 = 10000
+		amount  int64 = 100
+		n             = 10
+	)
+	repo := newTestMemoryRepository(t, MemoryOptions{ReadLatency: 5 * time.Millisecond, WriteLatency: 5 * time.Millisecond}, userID, initial)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			account, err := repo.GetAccount(ctx, userID)
+			if err != nil {
+				t.Errorf("get account: %v", err)
+				return
+			}
+			if err := repo.UpdateBalance(ctx, userID, account.Balance-amount); err != nil {
+				t.Errorf("update balance: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, exact := balanceOf(t, repo, userID), initial-n*amount; got <= exact {
+		t.Fatalf("balance = %d, expected lost updates to leave it above %d", got, exact)
+	}
+}
+
+func TestMemoryForUpdateSerializesTransactions(t *testing.T) {
+	const (
+		userID  int64 = 1
+		initial int64 = 10000
+		amount  int64 = 100
+		n             = 10
+	)
+	repo := newTestMemoryRepository(t, MemoryOptions{ReadLatency: 5 * time.Millisecond, WriteLatency: 5 * time.Millisecond}, userID, initial)
+	ctx := context.Background()
+
+	var inside, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.Transaction(ctx, nil, func(tx AccountRepository) error {
+				account, err := tx.GetAccountForUpdate(ctx, userID)
+				if err != nil {
+					return err
+				}
+				if atomic.AddInt32(&inside, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				defer atomic.AddInt32(&inside, -1)
+
+				time.Sleep(2 * time.Millisecond)
+				return tx.UpdateBalance(ctx, userID, account.Balance-amount)
+			})
+			if err != nil {
+				t.Errorf("transaction: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if overlaps != 0 {
+		t.Errorf("%d transactions held the row lock at the same time", overlaps)
+	}
+	if got, want := balanceOf(t, repo, userID), initial-n*amount; got != want {
+		t.Fatalf("balance = %d, want %d", got, want)
+	}
+}
+
+func TestMemoryTransactionBuffersWritesUntilCommit(t *testing.T) {
+	const userID int64 = 1
+	repo := newTestMemoryRepository(t, MemoryOptions{}, userID, 1000)
+	ctx := context.Background()
+
+	err := repo.Transaction(ctx, nil, func(tx AccountRepository) error {
+		if err := tx.UpdateBalance(ctx, userID, 500); err != nil {
+			return err
+		}
+		// 本事务读到自己的写入，事务外只能看到已提交的值
+		inside, err := tx.GetAccount(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if inside.Balance != 500 {
+			t.Errorf("balance inside tx = %d, want 500", inside.Balance)
+		}
+		if outside := balanceOf(t, repo, userID); outside != 1000 {
+			t.Errorf("balance outside tx = %d, want 1000", outside)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if got := balanceOf(t, repo, userID); got != 500 {
+		t.Fatalf("balance after commit = %d, want 500", got)
+	}
+}
+
+func TestMemoryTransactionRollback(t *testing.T) {
+	const userID int64 = 1
+	repo := newTestMemoryRepository(t, MemoryOptions{}, userID, 1000)
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	var holdID int64
+	err := repo.Transaction(ctx, nil, func(tx AccountRepository) error {
+		if err := tx.UpdateBalance(ctx, userID, 0); err != nil {
+			return err
+		}
+		if err := tx.AppendTransaction(ctx, &model.Transaction{UserID: userID, Type: model.TransactionTypeDeduct, Amount: 1000}); err != nil {
+			return err
+		}
+		hold := &model.Hold{UserID: userID, Amount: 100}
+		if err := tx.CreateHold(ctx, hold); err != nil {
+			return err
+		}
+		holdID = hold.ID
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction err = %v, want %v", err, errRollback)
+	}
+
+	if got := balanceOf(t, repo, userID); got != 1000 {
+		t.Errorf("balance after rollback = %d, want 1000", got)
+	}
+	if txns, _ := repo.ListTransactions(ctx, userID, 0, 10); len(txns) != 0 {
+		t.Errorf("ledger rows after rollback = %d, want 0", len(txns))
+	}
+	if _, err := repo.GetHold(ctx, holdID); !errors.Is(err, ErrHoldNotFound) {
+		t.Errorf("get hold after rollback err = %v, want ErrHoldNotFound", err)
+	}
+	if err := lockedWithin(repo, userID, 50*time.Millisecond); err != nil {
+		t.Errorf("row lock not released after rollback: %v", err)
+	}
+}
+
+func TestMemoryTransactionPanicReleasesRowLocks(t *testing.T) {
+	const userID int64 = 1
+	repo := newTestMemoryRepository(t, MemoryOptions{}, userID, 1000)
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic to propagate")
+			}
+		}()
+		repo.Transaction(ctx, nil, func(tx AccountRepository) error {
+			if err := tx.UpdateBalance(ctx, userID, 0); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if err := lockedWithin(repo, userID, 50*time.Millisecond); err != nil {
+		t.Fatalf("row lock not released after panic: %v", err)
+	}
+	if got := balanceOf(t, repo, userID); got != 1000 {
+		t.Fatalf("balance after panic = %d, want 1000", got)
+	}
+}
+
+func TestMemoryNestedTransactionReusesOuter(t *testing.T) {
+	const userID int64 = 1
+	repo := newTestMemoryRepository(t, MemoryOptions{}, userID, 1000)
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	err := repo.Transaction(ctx, &TxOptions{LockWaitTimeout: 50 * time.Millisecond}, func(tx AccountRepository) error {
+		if _, err := tx.GetAccountForUpdate(ctx, userID); err != nil {
+			return err
+		}
+		// 内层事务复用外层事务：已持有的行锁不会再次等待，写入随外层一起提交或回滚
+		err := tx.Transaction(ctx, nil, func(inner AccountRepository) error {
+			if _, err := inner.GetAccountForUpdate(ctx, userID); err != nil {
+				return err
+			}
+			return inner.UpdateBalance(ctx, userID, 0)
+		})
+		if err != nil {
+			return err
+		}
+		if got := balanceOf(t, repo, userID); got != 1000 {
+			t.Errorf("inner write visible before outer commit: balance = %d", got)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction err = %v, want %v", err, errRollback)
+	}
+	if got := balanceOf(t, repo, userID); got != 1000 {
+		t.Fatalf("balance after outer rollback = %d, want 1000", got)
+	}
+}
+
+func TestMemorySerializableReadTakesRowLock(t *testing.T) {
+	const userID int64 = 1
+	repo := newTestMemoryRepository(t, MemoryOptions{}, userID, 1000)
+	ctx := context.Background()
+
+	read := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.Transaction(ctx, &TxOptions{Isolation: sql.LevelSerializable}, func(tx AccountRepository) error {
+			if _, err := tx.GetAccount(ctx, userID); err != nil {
+				return err
+			}
+			close(read)
+			<-release
+			return nil
+		})
+	}()
+
+	<-read
+	if err := lockedWithin(repo, userID, 50*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("lock while serializable reader holds row: err = %v, want ErrLockTimeout", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("serializable transaction: %v", err)
+	}
+	if err := lockedWithin(repo, userID, 50*time.Millisecond); err != nil {
+		t.Fatalf("row lock not released after serializable commit: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// namedLocks 进程内命名锁，供不支持跨会话命名锁的存储（内存、SQLite）实现 WithAdvisoryLock
+// 只在单个进程内互斥，多实例部署时请使用 MySQL 或 PostgreSQL
+type namedLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{} // 容量为 1 的信号量
+}
+
+func newNamedLocks() *namedLocks {
+	return &namedLocks{locks: make(map[string]chan struct{})}
+}
+
+// with 持有命名锁执行 fn，timeout 内未获得锁返回 ErrLockTimeout
+func (n *namedLocks) with(ctx context.Context, name string, timeout time.Duration, fn func() error) error {
+	n.mu.Lock()
+	lock, ok := n.locks[name]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		n.locks[name] = lock
+	}
+	n.mu.Unlock()
+
+	if err := acquireLock(ctx, lock, timeout); err != nil {
+		return err
+	}
+	defer func() { <-lock }()
+
+	return fn()
+}
+
+// acquireLock 在 timeout 内获取信号量，超时返回 ErrLockTimeout
+func acquireLock(ctx context.Context, lock chan struct{}, timeout time.Duration) error {
+	select {
+	case lock <- struct{}{}:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case lock <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrLockTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
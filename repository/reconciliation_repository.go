@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"zero-balance-loss/model"
+)
+
+// ReconciliationRepository 对账报告存储，报告只追加不修改
+type ReconciliationRepository interface {
+	// SaveReconciliationReports 批量写入对账报告并回填 ID
+	SaveReconciliationReports(ctx context.Context, reports []model.ReconciliationReport) error
+
+	// ListReconciliationReports 按 ID 从新到旧列出对账报告，只返回 ID 小于 beforeID 的记录，beforeID 为 0 表示从最新开始
+	ListReconciliationReports(ctx context.Context, beforeID int64, limit int) ([]model.ReconciliationReport, error)
+}
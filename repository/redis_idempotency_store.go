@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"zero-balance-loss/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisIdempotencyStore 基于 Redis 的幂等键存储，记录以 JSON 保存在 <prefix><key> 下
+// Reserve 使用 SET NX PX，过期交给 Redis 的 key 过期机制
+type RedisIdempotencyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisIdempotencyStore 创建 Redis 幂等键存储
+func NewRedisIdempotencyStore(client redis.UniversalClient, prefix string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+// Reserve 占用 key
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*model.IdempotencyKey, error) {
+	now := time.Now()
+	value, err := json.Marshal(model.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.client.SetNX(ctx, s.prefix+key, value, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if ok {
+		return nil, nil
+	}
+
+	existing, err := s.Get(ctx, key)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	// 读取前恰好过期，重新占用一次
+	return s.Reserve(ctx, key, fingerprint, ttl)
+}
+
+// Get 读取 key 的记录
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) (*model.IdempotencyKey, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	var record model.IdempotencyKey
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, fmt.Errorf("invalid idempotency record %s: %w", key, err)
+	}
+	return &record, nil
+}
+
+// Complete 保存最终响应
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, body []byte, ttl time.Duration) error {
+	record, err := s.Get(ctx, key)
+	if err != nil || record == nil {
+		return err
+	}
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseBody = string(body)
+	record.ExpiresAt = time.Now().Add(ttl)
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// XX：记录已被删除（过期或 Release）时不再写回
+	if err := s.client.SetXX(ctx, s.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release 删除 key 的记录
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"zero-balance-loss/model"
+)
+
+var (
+	// seedInsertPattern 匹配 INSERT INTO accounts (列...) VALUES (...), (...) 语句
+	seedInsertPattern = regexp.MustCompile("(?is)INSERT\\s+INTO\\s+`?accounts`?\\s*\\(([^)]*)\\)\\s*VALUES\\s*(.*?)(?:ON\\s+DUPLICATE|;|$)")
+	// seedTuplePattern 匹配 VALUES 中的一组值
+	seedTuplePattern = regexp.MustCompile(`\(([^)]*)\)`)
+)
+
+// LoadSeedSQL 从 SQL 脚本（如 scripts/seed.sql）中提取 accounts 表的初始数据
+// 只识别 user_id、balance、version 三列，其他列和语句忽略
+func LoadSeedSQL(path string) ([]model.Account, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed file: %w", err)
+	}
+
+	// 去掉行注释，避免注释里的示例语句被当成数据
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	script := strings.Join(lines, "\n")
+
+	var accounts []model.Account
+	for _, stmt := range seedInsertPattern.FindAllStringSubmatch(script, -1) {
+		columns := strings.Split(stmt[1], ",")
+		for _, tuple := range seedTuplePattern.FindAllStringSubmatch(stmt[2], -1) {
+			values := strings.Split(tuple[1], ",")
+			if len(values) != len(columns) {
+				return nil, fmt.Errorf("seed row %q: expected %d values, got %d", tuple[0], len(columns), len(values))
+			}
+
+			var account model.Account
+			for i, column := range columns {
+				column = strings.Trim(strings.TrimSpace(column), "`")
+				if column != "user_id" && column != "balance" && column != "version" {
+					continue
+				}
+				value, err := strconv.ParseInt(strings.TrimSpace(values[i]), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("seed row %q: invalid %s: %w", tuple[0], column, err)
+				}
+				switch column {
+				case "user_id":
+					account.UserID = value
+				case "balance":
+					account.Balance = value
+				case "version":
+					account.Version = value
+				}
+			}
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
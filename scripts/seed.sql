@@ -0,0 +1,13 @@
+-- 测试数据：初始余额 1000.00 元 = 100000 分
+-- 服务启动时加载（database.seed_file），已存在的账户不会被覆盖
+INSERT INTO accounts (user_id, balance) VALUES (1, 100000);
+
+-- 查询验证
+SELECT
+    id,
+    user_id,
+    balance,
+    version,
+    created_at,
+    updated_at
+FROM accounts;
@@ -1,23 +1,59 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
-	"zero-balance-loss/config"
 	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
 )
 
-// 全局互斥锁，用于加锁模式
-var accountMutex sync.Mutex
+// ErrInsufficientBalance 余额不足
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ErrInvalidAmount 扣款或入账金额不是正数
+var ErrInvalidAmount = errors.New("amount must be positive")
 
 // DeductRequest 扣款请求
+// Credit 为 true 时表示入账：各策略的并发控制方式不变，只是不检查余额并把金额加到余额上，
+// 因此不加锁时入账同样会被覆盖，扣款被入账覆盖时余额会凭空变多
 type DeductRequest struct {
 	UserID int64 `json:"user_id" binding:"required"`
 	Amount int64 `json:"amount" binding:"required"` // 单位：分
+	Credit bool  `json:"-"`                         // 是否入账，由 /api/credit 设置，不从请求体读取
+}
+
+// validate 检查金额为正数：负数扣款相当于绕过策略的入账，还会抵减累计扣款
+func (r *DeductRequest) validate() error {
+	if r.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// apply 计算本次变更后的余额
+func (r *DeductRequest) apply(balance int64) int64 {
+	return balance + r.delta()
+}
+
+// delta 本次变更的有符号金额：扣款为负，入账为正
+func (r *DeductRequest) delta() int64 {
+	if r.Credit {
+		return r.Amount
+	}
+	return -r.Amount
+}
+
+// transactionType 本次变更对应的流水类型
+func (r *DeductRequest) transactionType() string {
+	if r.Credit {
+		return model.TransactionTypeCredit
+	}
+	return model.TransactionTypeDeduct
 }
 
 // DeductResponse 扣款响应
@@ -26,7 +62,10 @@ type DeductResponse struct {
 	Balance    int64    `json:"balance"`     // 单位：分
 	OldBalance int64    `json:"old_balance"` // 单位：分
 	RequestID  string   `json:"request_id"`
-	Timeline   Timeline `json:"timeline"` // 时间线数据
+	Retries    int      `json:"retries"`               // 重试次数（仅重试类策略非零）
+	BatchSize  int      `json:"batch_size,omitempty"`  // 所在批次的请求数（仅批量提交策略）
+	ErrorClass string   `json:"error_class,omitempty"` // 最后一次重试的原因（如 deadlock），未重试时为空
+	Timeline   Timeline `json:"timeline"`              // 时间线数据
 }
 
 // Timeline 记录操作的时间线
@@ -37,38 +76,107 @@ type Timeline struct {
 	ComputeEnd   int64 `json:"compute_end"`   // 计算结束时间（纳秒）
 	WriteStart   int64 `json:"write_start"`   // 写入开始时间（纳秒）
 	WriteEnd     int64 `json:"write_end"`     // 写入结束时间（纳秒）
+
+	// 锁等待阶段，仅需要显式加锁的策略填充
+	LockWaitStart int64 `json:"lock_wait_start,omitempty"` // 开始等待锁的时间（纳秒）
+	LockWaitEnd   int64 `json:"lock_wait_end,omitempty"`   // 获得锁的时间（纳秒）
+
+	// 排队阶段，仅单写者（actor）策略填充
+	QueueWaitStart int64 `json:"queue_wait_start,omitempty"` // 进入队列的时间（纳秒）
+	QueueWaitEnd   int64 `json:"queue_wait_end,omitempty"`   // 开始处理的时间（纳秒）
+
+	// 攒批阶段，仅批量提交策略填充
+	BatchWaitStart int64 `json:"batch_wait_start,omitempty"` // 加入批次的时间（纳秒）
+	BatchWaitEnd   int64 `json:"batch_wait_end,omitempty"`   // 批次开始提交的时间（纳秒）
+
+	// Attempts 每次尝试的明细，仅重试类策略（如乐观锁）填充
+	// 外层的 Read/Compute/Write 字段对应最后一次尝试
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt 单次尝试的时间线
+type Attempt struct {
+	Attempt      int   `json:"attempt"`       // 第几次尝试，从 1 开始
+	ReadStart    int64 `json:"read_start"`    // 读取开始时间（纳秒）
+	ReadEnd      int64 `json:"read_end"`      // 读取结束时间（纳秒）
+	ReadBalance  int64 `json:"read_balance"`  // 本次读取到的余额
+	ReadVersion  int64 `json:"read_version"`  // 本次读取到的版本号
+	ComputeStart int64 `json:"compute_start"` // 计算开始时间（纳秒）
+	ComputeEnd   int64 `json:"compute_end"`   // 计算结束时间（纳秒）
+	WriteStart   int64 `json:"write_start"`   // 写入开始时间（纳秒）
+	WriteEnd     int64 `json:"write_end"`     // 写入结束时间（纳秒）
+	Success      bool  `json:"success"`       // CAS 是否成功
 }
 
 // AccountService 账户服务
-type AccountService struct{}
+type AccountService struct {
+	repo         repository.AccountRepository // 账户存储，所有策略都通过它读写
+	strategies   *StrategyRegistry
+	accountLocks *KeyedLocker            // 按账户加锁，用于加锁模式
+	expected     *ExpectedBalanceTracker // 按账户跟踪的理论余额
+
+	redisMu     sync.Mutex
+	redisLocker *RedisLocker // Redis 分布式锁，首次使用时创建
+
+	actorOnce       sync.Once
+	actors          *ActorManager // 单写者策略的账户协程，首次使用时创建
+	actorObserverMu sync.RWMutex
+	actorObserver   func(ActorQueueStats)
+
+	batchOnce sync.Once
+	batches   *BatchManager // 批量提交策略的攒批器，首次使用时创建
+}
+
+// NewAccountService 创建账户服务实例，并注册内置扣款策略
+func NewAccountService(repo repository.AccountRepository) *AccountService {
+	s := &AccountService{
+		repo:         repo,
+		strategies:   NewStrategyRegistry(),
+		accountLocks: NewKeyedLocker(),
+		expected:     NewExpectedBalanceTracker(),
+	}
+	registerBuiltinStrategies(s)
+	return s
+}
+
+// Strategies 返回扣款策略注册表，可在启动时注册自定义策略
+func (s *AccountService) Strategies() *StrategyRegistry {
+	return s.strategies
+}
+
+// LockWaitStats 返回加锁模式下每个账户的锁等待统计
+func (s *AccountService) LockWaitStats() []KeyLockStats {
+	return s.accountLocks.Stats()
+}
 
-// NewAccountService 创建账户服务实例
-func NewAccountService() *AccountService {
-	return &AccountService{}
+// ResetLockWaitStats 清空锁等待统计
+func (s *AccountService) ResetLockWaitStats() {
+	s.accountLocks.ResetStats()
 }
 
 // GetAccount 获取账户信息
 func (s *AccountService) GetAccount(userID int64) (*model.Account, error) {
-	db := config.GetDB()
-	var account model.Account
+	return s.getAccount(context.Background(), userID)
+}
 
-	if err := db.Where("user_id = ?", userID).First(&account).Error; err != nil {
+// getAccount 带 context 读取账户，供各扣款策略使用
+func (s *AccountService) getAccount(ctx context.Context, userID int64) (*model.Account, error) {
+	account, err := s.repo.GetAccount(ctx, userID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
-
-	return &account, nil
+	return account, nil
 }
 
 // DeductBalance 扣减余额（故意不加锁，演示并发问题）
 // 这是一个有问题的实现，会导致并发场景下的余额丢失
-func (s *AccountService) DeductBalance(req *DeductRequest, requestID string) (*DeductResponse, error) {
-	db := config.GetDB()
+func (s *AccountService) DeductBalance(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
 	var timeline Timeline
 
 	// 步骤1: 查询当前余额
 	timeline.ReadStart = time.Now().UnixNano()
 	log.Printf("[%s] Step 1: 读取账户 user_id=%d", requestID, req.UserID)
-	account, err := s.GetAccount(req.UserID)
+	account, err := s.getAccount(ctx, req.UserID)
 	timeline.ReadEnd = time.Now().UnixNano()
 	if err != nil {
 		return nil, err
@@ -77,9 +185,9 @@ func (s *AccountService) DeductBalance(req *DeductRequest, requestID string) (*D
 	oldBalance := account.Balance
 	log.Printf("[%s] Step 2: 当前余额=%d分 (%.2f元)", requestID, oldBalance, float64(oldBalance)/100)
 
-	// 步骤2: 检查余额是否充足
-	if account.Balance < req.Amount {
-		return nil, errors.New("insufficient balance")
+	// 步骤2: 按账户策略检查余额和限额（入账不检查）
+	if err := checkDeduct(ctx, s.repo, req, account); err != nil {
+		return nil, err
 	}
 
 	// 步骤3: 计算阶段（包含业务延迟）
@@ -87,23 +195,18 @@ func (s *AccountService) DeductBalance(req *DeductRequest, requestID string) (*D
 	// 模拟一些处理时间，增加并发冲突的概率
 	time.Sleep(10 * time.Millisecond)
 	// 计算新余额
-	newBalance := account.Balance - req.Amount
+	newBalance := req.apply(account.Balance)
 	log.Printf("[%s] Step 3: 计算新余额=%d分 (%.2f元)", requestID, newBalance, float64(newBalance)/100)
 	timeline.ComputeEnd = time.Now().UnixNano()
 
 	// 步骤4: 更新数据库（问题所在：基于读取时的旧值更新，没有任何并发保护）
-	// 这里使用 Update 而不是事务，会导致 Lost Update 问题
-	timeline.WriteStart = time.Now().UnixNano()
-	result := db.Model(&model.Account{}).
-		Where("user_id = ?", req.UserID).
-		Update("balance", newBalance)
-	timeline.WriteEnd = time.Now().UnixNano()
-
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", result.Error)
+	// 读取在事务外完成，这里的无条件写入会覆盖期间其他请求的写入，导致 Lost Update 问题；
+	// 同一事务内追加的流水只保证"写入即留痕"，并不能阻止覆盖
+	if err := s.writeBalanceWithLedger(ctx, req, requestID, StrategyUnlocked, oldBalance, newBalance, &timeline); err != nil {
+		return nil, err
 	}
 
-	log.Printf("[%s] Step 4: 更新成功，影响行数=%d", requestID, result.RowsAffected)
+	log.Printf("[%s] Step 4: 更新成功，新余额=%d分", requestID, newBalance)
 
 	return &DeductResponse{
 		UserID:     req.UserID,
@@ -115,19 +218,20 @@ func (s *AccountService) DeductBalance(req *DeductRequest, requestID string) (*D
 }
 
 // DeductBalanceWithLock 扣减余额（加锁版本，解决并发问题）
-// 使用互斥锁保护临界区，确保并发安全
-func (s *AccountService) DeductBalanceWithLock(req *DeductRequest, requestID string) (*DeductResponse, error) {
-	// 🔒 加锁：进入临界区
-	accountMutex.Lock()
-	defer accountMutex.Unlock() // 确保函数返回时释放锁
-
-	db := config.GetDB()
+// 使用按账户划分的互斥锁保护临界区，同一账户串行，不同账户并行
+func (s *AccountService) DeductBalanceWithLock(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
 	var timeline Timeline
 
+	// 🔒 加锁：进入临界区
+	timeline.LockWaitStart = time.Now().UnixNano()
+	unlock, _ := s.accountLocks.Lock(req.UserID)
+	defer unlock() // 确保函数返回时释放锁
+	timeline.LockWaitEnd = time.Now().UnixNano()
+
 	// 步骤1: 查询当前余额
 	timeline.ReadStart = time.Now().UnixNano()
 	log.Printf("[%s] 🔒 [LOCKED] Step 1: 读取账户 user_id=%d", requestID, req.UserID)
-	account, err := s.GetAccount(req.UserID)
+	account, err := s.getAccount(ctx, req.UserID)
 	timeline.ReadEnd = time.Now().UnixNano()
 	if err != nil {
 		return nil, err
@@ -136,9 +240,9 @@ func (s *AccountService) DeductBalanceWithLock(req *DeductRequest, requestID str
 	oldBalance := account.Balance
 	log.Printf("[%s] 🔒 [LOCKED] Step 2: 当前余额=%d分 (%.2f元)", requestID, oldBalance, float64(oldBalance)/100)
 
-	// 步骤2: 检查余额是否充足
-	if account.Balance < req.Amount {
-		return nil, errors.New("insufficient balance")
+	// 步骤2: 按账户策略检查余额和限额（入账不检查）
+	if err := checkDeduct(ctx, s.repo, req, account); err != nil {
+		return nil, err
 	}
 
 	// 步骤3: 计算阶段（包含业务延迟）
@@ -146,22 +250,67 @@ func (s *AccountService) DeductBalanceWithLock(req *DeductRequest, requestID str
 	// 模拟一些处理时间
 	time.Sleep(10 * time.Millisecond)
 	// 计算新余额
-	newBalance := account.Balance - req.Amount
+	newBalance := req.apply(account.Balance)
 	log.Printf("[%s] 🔒 [LOCKED] Step 3: 计算新余额=%d分 (%.2f元)", requestID, newBalance, float64(newBalance)/100)
 	timeline.ComputeEnd = time.Now().UnixNano()
 
 	// 步骤4: 更新数据库（在锁的保护下，安全更新）
-	timeline.WriteStart = time.Now().UnixNano()
-	result := db.Model(&model.Account{}).
-		Where("user_id = ?", req.UserID).
-		Update("balance", newBalance)
-	timeline.WriteEnd = time.Now().UnixNano()
+	if err := s.writeBalanceWithLedger(ctx, req, requestID, StrategyMutex, oldBalance, newBalance, &timeline); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] 🔒 [LOCKED] Step 4: 更新成功，新余额=%d分", requestID, newBalance)
+
+	return &DeductResponse{
+		UserID:     req.UserID,
+		Balance:    newBalance,
+		OldBalance: oldBalance,
+		RequestID:  requestID,
+		Timeline:   timeline,
+	}, nil
+}
+
+// deductInCriticalSection 在调用方已持有锁的前提下执行"读取-计算-写入"
+// 供进程外加锁的策略复用；strategy 为写入流水的策略名，
+// beforeWrite 在写入前调用，用于确认锁仍然有效（如 Redis 租约未丢失）
+func (s *AccountService) deductInCriticalSection(ctx context.Context, req *DeductRequest, requestID, strategy, tag string, beforeWrite func() error) (*DeductResponse, error) {
+	var timeline Timeline
 
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update balance: %w", result.Error)
+	// 步骤1: 查询当前余额
+	timeline.ReadStart = time.Now().UnixNano()
+	log.Printf("[%s] %s Step 1: 读取账户 user_id=%d", requestID, tag, req.UserID)
+	account, err := s.getAccount(ctx, req.UserID)
+	timeline.ReadEnd = time.Now().UnixNano()
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("[%s] 🔒 [LOCKED] Step 4: 更新成功，影响行数=%d", requestID, result.RowsAffected)
+	oldBalance := account.Balance
+	log.Printf("[%s] %s Step 2: 当前余额=%d分 (%.2f元)", requestID, tag, oldBalance, float64(oldBalance)/100)
+
+	// 步骤2: 按账户策略检查余额和限额（入账不检查）
+	if err := checkDeduct(ctx, s.repo, req, account); err != nil {
+		return nil, err
+	}
+
+	// 步骤3: 计算阶段（与其他模式保持相同的业务延迟，方便对比）
+	timeline.ComputeStart = time.Now().UnixNano()
+	time.Sleep(10 * time.Millisecond)
+	newBalance := req.apply(account.Balance)
+	timeline.ComputeEnd = time.Now().UnixNano()
+
+	if beforeWrite != nil {
+		if err := beforeWrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 步骤4: 更新数据库并追加流水
+	if err := s.writeBalanceWithLedger(ctx, req, requestID, strategy, oldBalance, newBalance, &timeline); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] %s Step 4: 更新成功，新余额=%d分", requestID, tag, newBalance)
 
 	return &DeductResponse{
 		UserID:     req.UserID,
@@ -182,15 +331,27 @@ func (s *AccountService) GetBalance(userID int64) (int64, error) {
 }
 
 // ResetBalance 重置账户余额（用于测试）
+// 账户不存在返回 ErrAccountNotFound，已关闭返回 ErrAccountClosed
 func (s *AccountService) ResetBalance(userID int64, balance int64) error {
-	db := config.GetDB()
-	result := db.Model(&model.Account{}).
-		Where("user_id = ?", userID).
-		Update("balance", balance)
-
-	if result.Error != nil {
-		return fmt.Errorf("failed to reset balance: %w", result.Error)
+	ctx := context.Background()
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		account, err := tx.GetAccountForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if account.Closed() {
+			return ErrAccountClosed
+		}
+		if err := tx.UpdateBalance(ctx, userID, balance); err != nil {
+			return err
+		}
+		// 重置流水是对账重放的起点
+		return tx.AppendTransaction(ctx, newResetTransaction(userID, account.Balance, balance))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset balance: %w", err)
 	}
+	s.expected.Reset(userID, balance)
 
 	log.Printf("重置账户余额: user_id=%d, balance=%d分 (%.2f元)", userID, balance, float64(balance)/100)
 	return nil
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+// ErrAccountClosed 账户已关闭
+var ErrAccountClosed = errors.New("account closed")
+
+// ensureActive 在写事务内加锁读取账户，确认仍未关闭
+// 读取在事务外完成的策略靠它与 CloseAccount 串行：关闭先提交时这里看到 closed，否则关闭要等本事务提交
+func ensureActive(ctx context.Context, tx repository.AccountRepository, userID int64) error {
+	account, err := tx.GetAccountForUpdate(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+	if account.Closed() {
+		return ErrAccountClosed
+	}
+	return nil
+}
+
+// 账户列表分页参数，压测时账户数量可能很多，单页上限比流水大
+const (
+	defaultAccountPageSize = 50
+	maxAccountPageSize     = 1000
+)
+
+// AccountQuery 账户列表查询条件
+type AccountQuery struct {
+	Cursor     int64  // 上一页返回的 NextCursor（最后一个 user_id），0 表示第一页
+	MinBalance *int64 // 余额下限（含），单位：分
+	MaxBalance *int64 // 余额上限（含），单位：分
+	Status     string // 账户状态，空表示不限
+	Limit      int
+}
+
+// AccountPage 一页账户，按 user_id 升序
+type AccountPage struct {
+	Items      []model.Account `json:"items"`
+	NextCursor int64           `json:"next_cursor,omitempty"` // 下一页的游标，为 0 表示没有更多
+	HasMore    bool            `json:"has_more"`
+}
+
+// CreateAccount 创建账户，user_id 已存在返回 ErrAccountExists
+// 开户时同一事务内追加一条重置流水（0 -> balance），作为对账重放的起点
+func (s *AccountService) CreateAccount(ctx context.Context, userID int64, balance int64) (*model.Account, error) {
+	account := &model.Account{
+		UserID:  userID,
+		Balance: balance,
+		Status:  model.AccountStatusActive,
+	}
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		if err := tx.CreateAccount(ctx, account); err != nil {
+			return err
+		}
+		return tx.AppendTransaction(ctx, newResetTransaction(userID, 0, balance))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+	s.expected.Reset(userID, balance)
+
+	log.Printf("创建账户: user_id=%d, balance=%d分 (%.2f元)", userID, balance, float64(balance)/100)
+	return account, nil
+}
+
+// FindAccounts 按条件分页查询账户
+func (s *AccountService) FindAccounts(ctx context.Context, query AccountQuery) (*AccountPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultAccountPageSize
+	}
+	if limit > maxAccountPageSize {
+		limit = maxAccountPageSize
+	}
+
+	// 多取一条用于判断是否还有下一页
+	accounts, err := s.repo.FindAccounts(ctx, repository.AccountFilter{
+		AfterUserID: query.Cursor,
+		MinBalance:  query.MinBalance,
+		MaxBalance:  query.MaxBalance,
+		Status:      query.Status,
+		Limit:       limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	page := &AccountPage{Items: accounts}
+	if len(accounts) > limit {
+		page.Items = accounts[:limit]
+		page.HasMore = true
+		page.NextCursor = page.Items[limit-1].UserID
+	}
+	if page.Items == nil {
+		page.Items = []model.Account{}
+	}
+	return page, nil
+}
+
+// CloseAccount 关闭账户，已关闭返回 ErrAccountClosed
+// 关闭只修改状态，余额和流水保持不变，之后的扣款和重置都会被拒绝；
+// 仍在生效的冻结在同一事务内撤销，冻结金额随之释放，不必等待过期
+func (s *AccountService) CloseAccount(ctx context.Context, userID int64) (*model.Account, error) {
+	var closed *model.Account
+	voided := 0
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		account, err := tx.GetAccountForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if account.Closed() {
+			return ErrAccountClosed
+		}
+		voided, err = voidActiveHolds(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.UpdateAccountStatus(ctx, userID, model.AccountStatusClosed); err != nil {
+			return err
+		}
+		closed, err = tx.GetAccount(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to close account: %w", err)
+	}
+
+	log.Printf("关闭账户: user_id=%d, balance=%d分 (%.2f元)，撤销冻结 %d 笔", userID, closed.Balance, float64(closed.Balance)/100, voided)
+	return closed, nil
+}
+
+// voidActiveHolds 在调用方已锁定账户行的事务内撤销账户的全部生效冻结，返回撤销的数量
+// 与 releaseHold 相同，先写账户行再写冻结行
+func voidActiveHolds(ctx context.Context, tx repository.AccountRepository, userID int64) (int, error) {
+	batchSize := holdSettings().SweepBatchSize
+	voided := 0
+	var afterID int64
+	for {
+		holds, err := tx.FindHolds(ctx, repository.HoldFilter{
+			UserID:  userID,
+			Status:  model.HoldStatusActive,
+			AfterID: afterID,
+			Limit:   batchSize,
+		})
+		if err != nil {
+			return voided, fmt.Errorf("failed to list holds: %w", err)
+		}
+		for _, hold := range holds {
+			if _, err := tx.AdjustHeldBalance(ctx, userID, repository.HeldAdjustment{Held: -hold.Amount}); err != nil {
+				return voided, fmt.Errorf("failed to update balance: %w", err)
+			}
+			finished, err := tx.FinishHold(ctx, hold.ID, model.HoldStatusVoided, 0)
+			if err != nil {
+				return voided, fmt.Errorf("failed to update hold: %w", err)
+			}
+			if !finished {
+				return voided, ErrHoldNotActive
+			}
+			voided++
+		}
+		if len(holds) < batchSize {
+			return voided, nil
+		}
+		afterID = holds[len(holds)-1].ID
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zero-balance-loss/config"
+)
+
+// StrategyActor 单写者策略名称
+const StrategyActor = "actor"
+
+// ErrQueueFull 账户请求队列已满
+var ErrQueueFull = errors.New("account request queue full")
+
+// 单写者策略默认配置，config.yaml 未配置时使用
+const (
+	defaultActorQueueSize = 256
+	defaultActorIdleTTLMs = 30000
+)
+
+// actorSettings 读取单写者策略配置，未配置的项使用默认值
+func actorSettings() config.ActorConfig {
+	settings := config.ActorConfig{
+		QueueSize: defaultActorQueueSize,
+		IdleTTLMs: defaultActorIdleTTLMs,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.Actor.QueueSize > 0 {
+		settings.QueueSize = cfg.Strategy.Actor.QueueSize
+	}
+	if cfg.Strategy.Actor.IdleTTLMs > 0 {
+		settings.IdleTTLMs = cfg.Strategy.Actor.IdleTTLMs
+	}
+	return settings
+}
+
+// ActorQueueStats 单个账户协程的队列状态
+type ActorQueueStats struct {
+	UserID    int64 `json:"user_id"`
+	Depth     int   `json:"depth"`     // 排队中 + 处理中的请求数
+	Capacity  int   `json:"capacity"`  // 队列容量
+	Processed int64 `json:"processed"` // 累计处理的请求数
+}
+
+// actorRequest 投递给账户协程的一次扣款请求
+type actorRequest struct {
+	ctx        context.Context
+	req        *DeductRequest
+	requestID  string
+	enqueuedAt int64
+	reply      chan actorResult // 容量为 1，账户协程写入后不会阻塞
+	started    atomic.Bool      // 账户协程已开始处理该请求，此后结果以 reply 为准
+}
+
+// actorResult 账户协程处理结果
+type actorResult struct {
+	resp *DeductResponse
+	err  error
+}
+
+// accountActor 账户协程，独占该账户的余额变更
+type accountActor struct {
+	userID    int64
+	requests  chan *actorRequest
+	busy      bool  // 是否正在处理请求，受 ActorManager.mu 保护
+	processed int64 // 受 ActorManager.mu 保护
+}
+
+// ActorManager 管理所有账户协程
+// 每个账户一个协程，通过有界 channel 串行处理该账户的扣款，
+// 用排队代替锁竞争；协程空闲超过 idleTTL 后自动退出
+type ActorManager struct {
+	svc       *AccountService
+	queueSize int
+	idleTTL   time.Duration
+
+	mu     sync.Mutex
+	actors map[int64]*accountActor
+}
+
+// NewActorManager 创建账户协程管理器
+func NewActorManager(svc *AccountService, queueSize int, idleTTL time.Duration) *ActorManager {
+	return &ActorManager{
+		svc:       svc,
+		queueSize: queueSize,
+		idleTTL:   idleTTL,
+		actors:    make(map[int64]*accountActor),
+	}
+}
+
+// Submit 投递请求并等待结果
+// 队列满时立即返回 ErrQueueFull；ctx 取消时放弃等待，尚未处理的请求会被账户协程跳过，已开始处理的请求等待其结果
+func (m *ActorManager) Submit(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	r := &actorRequest{
+		ctx:        ctx,
+		req:        req,
+		requestID:  requestID,
+		enqueuedAt: time.Now().UnixNano(),
+		reply:      make(chan actorResult, 1),
+	}
+
+	// 投递在 m.mu 内完成，保证账户协程判断"空闲可回收"时不会有请求同时入队
+	m.mu.Lock()
+	actor, ok := m.actors[req.UserID]
+	if !ok {
+		actor = &accountActor{
+			userID:   req.UserID,
+			requests: make(chan *actorRequest, m.queueSize),
+		}
+		m.actors[req.UserID] = actor
+		go m.run(actor)
+	}
+	select {
+	case actor.requests <- r:
+	default:
+		m.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	stats := m.statsLocked(actor)
+	m.mu.Unlock()
+
+	m.svc.notifyActorQueue(stats)
+
+	select {
+	case res := <-r.reply:
+		return res.resp, res.err
+	case <-ctx.Done():
+		// 已经开始处理的请求可能已经扣款成功，等待处理结果而不是返回 ctx 错误
+		if r.started.Load() {
+			res := <-r.reply
+			return res.resp, res.err
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// run 账户协程主循环
+func (m *ActorManager) run(actor *accountActor) {
+	idle := time.NewTimer(m.idleTTL)
+	defer idle.Stop()
+
+	for {
+		select {
+		case r := <-actor.requests:
+			m.mu.Lock()
+			actor.busy = true
+			m.mu.Unlock()
+
+			m.handle(r)
+
+			m.mu.Lock()
+			actor.busy = false
+			actor.processed++
+			stats := m.statsLocked(actor)
+			m.mu.Unlock()
+			m.svc.notifyActorQueue(stats)
+
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(m.idleTTL)
+
+		case <-idle.C:
+			m.mu.Lock()
+			if len(actor.requests) > 0 {
+				// 计时器到期的同时有新请求入队，继续服务
+				m.mu.Unlock()
+				idle.Reset(m.idleTTL)
+				continue
+			}
+			delete(m.actors, actor.userID)
+			m.mu.Unlock()
+			log.Printf("🎭 [ACTOR] 账户 %d 协程空闲超过 %v，已回收", actor.userID, m.idleTTL)
+			return
+		}
+	}
+}
+
+// handle 处理单个请求，调用方已经放弃等待的请求直接跳过
+func (m *ActorManager) handle(r *actorRequest) {
+	// 先标记再检查 ctx：调用方看到未标记时返回 ctx 错误，这里一定会跳过该请求
+	r.started.Store(true)
+	if err := r.ctx.Err(); err != nil {
+		r.reply <- actorResult{err: err}
+		return
+	}
+
+	queueWaitEnd := time.Now().UnixNano()
+	resp, err := m.svc.deductInCriticalSection(r.ctx, r.req, r.requestID, StrategyActor, "🎭 [ACTOR]", nil)
+	if err == nil {
+		resp.Timeline.QueueWaitStart = r.enqueuedAt
+		resp.Timeline.QueueWaitEnd = queueWaitEnd
+	}
+	r.reply <- actorResult{resp: resp, err: err}
+}
+
+// statsLocked 计算单个账户协程的队列状态，调用方需持有 m.mu
+func (m *ActorManager) statsLocked(actor *accountActor) ActorQueueStats {
+	depth := len(actor.requests)
+	if actor.busy {
+		depth++
+	}
+	return ActorQueueStats{
+		UserID:    actor.userID,
+		Depth:     depth,
+		Capacity:  cap(actor.requests),
+		Processed: actor.processed,
+	}
+}
+
+// Stats 返回所有存活账户协程的队列状态，按 user_id 排序
+func (m *ActorManager) Stats() []ActorQueueStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]ActorQueueStats, 0, len(m.actors))
+	for _, actor := range m.actors {
+		result = append(result, m.statsLocked(actor))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// getActorManager 获取账户协程管理器，首次使用时按配置创建
+func (s *AccountService) getActorManager() *ActorManager {
+	s.actorOnce.Do(func() {
+		settings := actorSettings()
+		s.actors = NewActorManager(s, settings.QueueSize, time.Duration(settings.IdleTTLMs)*time.Millisecond)
+	})
+	return s.actors
+}
+
+// ActorQueueStats 返回单写者策略下每个账户的队列状态
+func (s *AccountService) ActorQueueStats() []ActorQueueStats {
+	return s.getActorManager().Stats()
+}
+
+// OnActorQueueChange 注册队列深度变化的回调，用于向前端推送排队情况
+func (s *AccountService) OnActorQueueChange(fn func(ActorQueueStats)) {
+	s.actorObserverMu.Lock()
+	defer s.actorObserverMu.Unlock()
+	s.actorObserver = fn
+}
+
+// notifyActorQueue 通知队列深度变化
+func (s *AccountService) notifyActorQueue(stats ActorQueueStats) {
+	s.actorObserverMu.RLock()
+	fn := s.actorObserver
+	s.actorObserverMu.RUnlock()
+
+	if fn != nil {
+		fn(stats)
+	}
+}
+
+// DeductBalanceWithActor 扣减余额（单写者版本）
+// 请求按账户投递到专属协程排队执行，同一账户的"读取-计算-写入"天然串行
+func (s *AccountService) DeductBalanceWithActor(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return s.getActorManager().Submit(ctx, req, requestID)
+}
+
+// actorStrategy 单写者策略，对应 DeductBalanceWithActor
+type actorStrategy struct {
+	svc *AccountService
+}
+
+func (st *actorStrategy) Name() string { return StrategyActor }
+
+func (st *actorStrategy) Description() string {
+	return "单写者：每个账户一个协程，通过有界队列串行处理扣款，用排队代替锁竞争"
+}
+
+func (st *actorStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceWithActor(ctx, req, requestID)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/config"
+)
+
+// StrategyAdvisory MySQL 用户级锁策略名称
+const StrategyAdvisory = "advisory"
+
+// defaultAdvisoryLockTimeoutSec GET_LOCK 默认等待超时，config.yaml 未配置时使用
+const defaultAdvisoryLockTimeoutSec = 5
+
+// advisoryLockSettings 读取 MySQL 用户级锁配置，未配置的项使用默认值
+func advisoryLockSettings() config.AdvisoryLockConfig {
+	settings := config.AdvisoryLockConfig{
+		TimeoutSec: defaultAdvisoryLockTimeoutSec,
+	}
+
+	cfg := config.GetConfig()
+	if cfg != nil && cfg.Strategy.AdvisoryLock.TimeoutSec > 0 {
+		settings.TimeoutSec = cfg.Strategy.AdvisoryLock.TimeoutSec
+	}
+	return settings
+}
+
+// advisoryLockName 账户对应的用户级锁名称
+func advisoryLockName(userID int64) string {
+	return fmt.Sprintf("acct:%d", userID)
+}
+
+// DeductBalanceWithAdvisoryLock 扣减余额（MySQL 用户级锁版本）
+// 由存储层在固定连接上 GET_LOCK 加锁、执行临界区、RELEASE_LOCK 释放。
+// 介于进程内互斥锁和 Redis 之间：多实例共享同一个 MySQL 时同样互斥，且不需要额外组件
+func (s *AccountService) DeductBalanceWithAdvisoryLock(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	settings := advisoryLockSettings()
+	lockName := advisoryLockName(req.UserID)
+
+	var resp *DeductResponse
+	lockWaitStart := time.Now().UnixNano()
+	err := s.repo.WithAdvisoryLock(ctx, lockName, time.Duration(settings.TimeoutSec)*time.Second, func() error {
+		lockWaitEnd := time.Now().UnixNano()
+
+		var err error
+		resp, err = s.deductInCriticalSection(ctx, req, requestID, StrategyAdvisory, "🗝️ [ADVISORY]", nil)
+		if err != nil {
+			return err
+		}
+
+		resp.Timeline.LockWaitStart = lockWaitStart
+		resp.Timeline.LockWaitEnd = lockWaitEnd
+		return nil
+	})
+	if errors.Is(err, ErrLockTimeout) {
+		log.Printf("[%s] 🗝️ [ADVISORY] 获取锁 %s 超时（%ds）", requestID, lockName, settings.TimeoutSec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// advisoryStrategy MySQL 用户级锁策略，对应 DeductBalanceWithAdvisoryLock
+type advisoryStrategy struct {
+	svc *AccountService
+}
+
+func (st *advisoryStrategy) Name() string { return StrategyAdvisory }
+
+func (st *advisoryStrategy) Description() string {
+	return "MySQL 用户级锁：固定连接上 GET_LOCK('acct:<id>') 加锁，超时返回 409，无需额外组件即可跨实例互斥"
+}
+
+func (st *advisoryStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceWithAdvisoryLock(ctx, req, requestID)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/repository"
+)
+
+// StrategyAtomic 原子条件更新策略名称
+const StrategyAtomic = "atomic"
+
+// DeductBalanceAtomic 扣减余额（原子条件更新版本）
+// 余额从不读入 Go，只发出一条 UPDATE ... SET balance = balance - ? WHERE <账户策略条件>，
+// 由数据库在行锁内完成"读取-检查-写入"。影响行数为 0 表示余额不足、违反策略（或账户不存在）。
+// 累计扣款上限无法写进这条语句，扣减成功后在同一事务内汇总流水检查，超限时回滚。
+// 入账不需要检查余额，发出的是 UPDATE ... SET balance = balance + ?。
+// MySQL 没有 RETURNING，因此在同一事务内回读新余额，事务提交前行锁仍被持有，回读值即本次写入结果
+func (s *AccountService) DeductBalanceAtomic(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	// 条件 UPDATE 不检查金额的符号，负数扣款会直接通过
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	var timeline Timeline
+	var newBalance int64
+
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		// 读取、计算、写入在一条语句内完成，三个阶段共用同一段时间
+		start := time.Now().UnixNano()
+		var applied bool
+		var err error
+		if req.Credit {
+			log.Printf("[%s] ⚛️ [ATOMIC] 原子入账 user_id=%d amount=%d", requestID, req.UserID, req.Amount)
+			err = tx.IncrementBalance(ctx, req.UserID, req.Amount)
+			applied = true
+		} else {
+			log.Printf("[%s] ⚛️ [ATOMIC] 条件扣减 user_id=%d amount=%d", requestID, req.UserID, req.Amount)
+			applied, err = tx.DecrementBalanceWithinPolicy(ctx, req.UserID, req.Amount)
+		}
+		end := time.Now().UnixNano()
+		timeline.ReadStart, timeline.ReadEnd = start, end
+		timeline.ComputeStart, timeline.ComputeEnd = start, end
+		timeline.WriteStart, timeline.WriteEnd = start, end
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		// 回读：既用于区分"账户不存在"和"余额不足"，也用于返回新余额
+		account, err := tx.GetAccount(ctx, req.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		if account.Closed() {
+			// 扣款的条件 UPDATE 不会命中已关闭的账户；入账是无条件 UPDATE，行锁已持有，回读到已关闭则回滚
+			return ErrAccountClosed
+		}
+		if !applied {
+			// 条件不满足时按回读到的账户重新判定，给出具体违反的策略
+			if err := checkPolicy(account.Policy, account.Available(), req.Amount); err != nil {
+				return err
+			}
+			return ErrInsufficientBalance
+		}
+		if !req.Credit {
+			// 累计上限需要汇总流水，在同一事务内持有行锁时检查，超限则整体回滚
+			spent, err := dailySpent(ctx, tx, req.UserID, account.Policy)
+			if err != nil {
+				return err
+			}
+			if err := checkDailyLimit(account.Policy, spent, req.Amount); err != nil {
+				return err
+			}
+		}
+
+		newBalance = account.Balance
+		if err := s.appendDeductTransaction(ctx, tx, req, requestID, StrategyAtomic, newBalance-req.delta(), newBalance, &timeline); err != nil {
+			return err
+		}
+		log.Printf("[%s] ⚛️ [ATOMIC] 更新成功，新余额=%d分", requestID, newBalance)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeductResponse{
+		UserID:     req.UserID,
+		Balance:    newBalance,
+		OldBalance: newBalance - req.delta(),
+		RequestID:  requestID,
+		Timeline:   timeline,
+	}, nil
+}
+
+// atomicStrategy 原子条件更新策略，对应 DeductBalanceAtomic
+type atomicStrategy struct {
+	svc *AccountService
+}
+
+func (st *atomicStrategy) Name() string { return StrategyAtomic }
+
+func (st *atomicStrategy) Description() string {
+	return "原子条件更新：UPDATE ... SET balance = balance - ? WHERE balance >= ?，余额不进入应用层"
+}
+
+func (st *atomicStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceAtomic(ctx, req, requestID)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+)
+
+// StrategyBatch 批量合并提交策略名称
+const StrategyBatch = "batch"
+
+// 批量提交默认配置，config.yaml 未配置时使用
+const (
+	defaultBatchWindowMs = 5
+	defaultBatchMaxBatch = 32
+)
+
+// batchSettings 读取批量提交配置，未配置的项使用默认值
+func batchSettings() config.BatchConfig {
+	settings := config.BatchConfig{
+		WindowMs: defaultBatchWindowMs,
+		MaxBatch: defaultBatchMaxBatch,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.Batch.WindowMs > 0 {
+		settings.WindowMs = cfg.Strategy.Batch.WindowMs
+	}
+	if cfg.Strategy.Batch.MaxBatch > 0 {
+		settings.MaxBatch = cfg.Strategy.Batch.MaxBatch
+	}
+	return settings
+}
+
+// batchRequest 等待合并提交的一次扣款请求
+type batchRequest struct {
+	ctx        context.Context
+	req        *DeductRequest
+	requestID  string
+	enqueuedAt int64
+	reply      chan actorResult // 容量为 1，提交协程写入后不会阻塞
+	started    atomic.Bool      // 提交协程已开始判定该请求，此后结果以 reply 为准
+}
+
+// accountBatch 某个账户正在收集中的批次
+type accountBatch struct {
+	userID   int64
+	requests []*batchRequest
+	timer    *time.Timer
+}
+
+// BatchManager 按账户攒批的合并提交器
+// 同一账户的并发请求在一个短窗口内（或攒满 maxBatch 个）合并为一个事务：
+// 事务内锁定账户行一次，按到达顺序逐个判定接受/拒绝，最后只写一次余额
+type BatchManager struct {
+	svc      *AccountService
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending map[int64]*accountBatch
+}
+
+// NewBatchManager 创建合并提交器
+func NewBatchManager(svc *AccountService, window time.Duration, maxBatch int) *BatchManager {
+	return &BatchManager{
+		svc:      svc,
+		window:   window,
+		maxBatch: maxBatch,
+		pending:  make(map[int64]*accountBatch),
+	}
+}
+
+// Submit 把请求加入所属账户的批次并等待该批次提交结果
+// ctx 取消时，尚未判定的请求会被提交协程跳过，已开始判定的请求等待提交结果
+func (m *BatchManager) Submit(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	r := &batchRequest{
+		ctx:        ctx,
+		req:        req,
+		requestID:  requestID,
+		enqueuedAt: time.Now().UnixNano(),
+		reply:      make(chan actorResult, 1),
+	}
+
+	m.mu.Lock()
+	batch, ok := m.pending[req.UserID]
+	if !ok {
+		batch = &accountBatch{userID: req.UserID}
+		m.pending[req.UserID] = batch
+		// 窗口从第一个请求到达开始计时
+		batch.timer = time.AfterFunc(m.window, func() { m.flush(batch) })
+	}
+	batch.requests = append(batch.requests, r)
+	full := len(batch.requests) >= m.maxBatch
+	m.mu.Unlock()
+
+	if full {
+		go m.flush(batch)
+	}
+
+	select {
+	case res := <-r.reply:
+		return res.resp, res.err
+	case <-ctx.Done():
+		// 已经进入提交的请求可能已经扣款成功，等待提交结果而不是返回 ctx 错误
+		if r.started.Load() {
+			res := <-r.reply
+			return res.resp, res.err
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// flush 提交批次；窗口到期和攒满可能同时触发，只有先摘下批次的一方执行
+func (m *BatchManager) flush(batch *accountBatch) {
+	m.mu.Lock()
+	if m.pending[batch.userID] != batch {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.pending, batch.userID)
+	batch.timer.Stop()
+	requests := batch.requests
+	m.mu.Unlock()
+
+	m.commit(batch.userID, requests)
+}
+
+// commit 在一个事务内提交整批请求，并把每个请求的结果分发回去
+func (m *BatchManager) commit(userID int64, requests []*batchRequest) {
+	batchWaitEnd := time.Now().UnixNano()
+	results := make([]actorResult, len(requests))
+	var timeline Timeline
+
+	err := m.svc.repo.Transaction(context.Background(), nil, func(tx repository.AccountRepository) error {
+		// 步骤1: 锁定账户行，与其他批次及其他策略的写入串行
+		timeline.LockWaitStart = time.Now().UnixNano()
+		account, err := tx.GetAccountForUpdate(context.Background(), userID)
+		timeline.LockWaitEnd = time.Now().UnixNano()
+		timeline.ReadStart = timeline.LockWaitStart
+		timeline.ReadEnd = timeline.LockWaitEnd
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		if account.Closed() {
+			return ErrAccountClosed
+		}
+
+		// 步骤2: 按到达顺序逐个判定，整批只付出一次业务延迟
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		// 策略按批内的运行余额逐个检查，窗口内已扣金额只汇总一次，本批接受的扣款随之累加
+		spent, err := dailySpent(context.Background(), tx, userID, account.Policy)
+		if err != nil {
+			return err
+		}
+		balance := account.Balance
+		accepted := 0
+		for i, r := range requests {
+			// 先标记再检查 ctx：调用方看到未标记时返回 ctx 错误，这里一定会跳过该请求
+			r.started.Store(true)
+			if err := r.ctx.Err(); err != nil {
+				// 调用方已放弃等待，不计入本批
+				results[i] = actorResult{err: err}
+				continue
+			}
+			if err := r.req.validate(); err != nil {
+				results[i] = actorResult{err: err}
+				continue
+			}
+			if !r.req.Credit {
+				err := checkPolicy(account.Policy, balance-account.HeldBalance, r.req.Amount)
+				if err == nil {
+					err = checkDailyLimit(account.Policy, spent, r.req.Amount)
+				}
+				if err != nil {
+					results[i] = actorResult{err: err}
+					continue
+				}
+				spent += r.req.Amount
+			}
+			results[i] = actorResult{resp: &DeductResponse{
+				UserID:     userID,
+				OldBalance: balance,
+				Balance:    r.req.apply(balance),
+				RequestID:  r.requestID,
+				BatchSize:  len(requests),
+			}}
+			balance = r.req.apply(balance)
+			accepted++
+		}
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		// 扣款和入账可能正好抵消，余额不变也要写入，否则被接受的请求没有流水
+		if accepted == 0 {
+			return nil
+		}
+
+		// 步骤3: 整批只写一次
+		timeline.WriteStart = time.Now().UnixNano()
+		err = tx.UpdateBalance(context.Background(), userID, balance)
+		timeline.WriteEnd = time.Now().UnixNano()
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		// 步骤4: 每个被接受的请求各追加一条流水，与余额写入同一事务提交
+		for i, r := range requests {
+			resp := results[i].resp
+			if resp == nil {
+				continue
+			}
+			if err := m.svc.appendDeductTransaction(context.Background(), tx, r.req, r.requestID, StrategyBatch, resp.OldBalance, resp.Balance, &timeline); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("📦 [BATCH] 账户 %d 合并提交 %d 个请求，余额 %d -> %d", userID, len(requests), account.Balance, balance)
+		return nil
+	})
+
+	for i, r := range requests {
+		res := results[i]
+		if err != nil {
+			res = actorResult{err: err}
+		} else if res.resp != nil {
+			res.resp.Timeline = timeline
+			res.resp.Timeline.BatchWaitStart = r.enqueuedAt
+			res.resp.Timeline.BatchWaitEnd = batchWaitEnd
+		}
+		r.reply <- res
+	}
+}
+
+// getBatchManager 获取合并提交器，首次使用时按配置创建
+func (s *AccountService) getBatchManager() *BatchManager {
+	s.batchOnce.Do(func() {
+		settings := batchSettings()
+		s.batches = NewBatchManager(s, time.Duration(settings.WindowMs)*time.Millisecond, settings.MaxBatch)
+	})
+	return s.batches
+}
+
+// DeductBalanceBatched 扣减余额（批量合并提交版本）
+// 热点账户上的并发请求合并成一个事务提交，用少量延迟换取吞吐
+func (s *AccountService) DeductBalanceBatched(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return s.getBatchManager().Submit(ctx, req, requestID)
+}
+
+// batchStrategy 批量合并提交策略，对应 DeductBalanceBatched
+type batchStrategy struct {
+	svc *AccountService
+}
+
+func (st *batchStrategy) Name() string { return StrategyBatch }
+
+func (st *batchStrategy) Description() string {
+	return "批量合并提交：同一账户的并发请求在短窗口内攒批，一个事务按到达顺序逐个判定后一次写入"
+}
+
+func (st *batchStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceBatched(ctx, req, requestID)
+}
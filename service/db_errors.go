@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"zero-balance-loss/repository"
+)
+
+// 存储层的哨兵错误，重新导出供 api 层判断
+var (
+	// ErrAccountNotFound 账户不存在
+	ErrAccountNotFound = repository.ErrAccountNotFound
+	// ErrAccountExists 创建账户时 user_id 已存在
+	ErrAccountExists = repository.ErrAccountExists
+	// ErrHoldNotFound 冻结记录不存在
+	ErrHoldNotFound = repository.ErrHoldNotFound
+	// ErrLockTimeout 等待锁超时（进程内锁、行锁、命名锁或 Redis 锁）
+	ErrLockTimeout = repository.ErrLockTimeout
+	// ErrDeadlock 数据库检测到死锁并回滚了当前事务
+	ErrDeadlock = repository.ErrDeadlock
+	// ErrSerializationFailure 事务无法串行化，需要整体重试（PostgreSQL 40001）
+	ErrSerializationFailure = repository.ErrSerializationFailure
+)
+
+// 错误分类，用于决定是否重试以及统计
+const (
+	ErrorClassDeadlock             = "deadlock"              // 死锁，数据库已回滚其中一个事务
+	ErrorClassLockWaitTimeout      = "lock_wait_timeout"     // 行锁等待超时
+	ErrorClassSerializationFailure = "serialization_failure" // 无法串行化（PostgreSQL 40001），数据库已回滚当前事务
+	ErrorClassVersionConflict      = "version_conflict"      // 乐观锁版本号不匹配
+	ErrorClassInsufficientBalance  = "insufficient_balance"  // 余额不足
+	ErrorClassOther                = "other"                 // 其他不可重试的错误
+)
+
+// RetryError 经过重试后仍然失败的错误，携带重试次数和最终错误分类
+type RetryError struct {
+	Retries int    // 已重试次数（不含首次）
+	Class   string // 最终错误的分类
+	Err     error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (class=%s, retries=%d)", e.Err, e.Class, e.Retries)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError 对扣款过程中的错误分类，违反账户策略时返回具体的违规代码
+func ClassifyError(err error) string {
+	var policyErr *PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		return policyErr.Code
+	case errors.Is(err, ErrDeadlock):
+		return ErrorClassDeadlock
+	case errors.Is(err, ErrLockTimeout):
+		return ErrorClassLockWaitTimeout
+	case errors.Is(err, ErrSerializationFailure):
+		return ErrorClassSerializationFailure
+	case errors.Is(err, ErrOptimisticConflict):
+		return ErrorClassVersionConflict
+	case errors.Is(err, ErrInsufficientBalance):
+		return ErrorClassInsufficientBalance
+	default:
+		return ErrorClassOther
+	}
+}
+
+// isRetryableClass 该类错误重试整个事务是否有可能成功
+func isRetryableClass(class string) bool {
+	return class == ErrorClassDeadlock || class == ErrorClassLockWaitTimeout || class == ErrorClassSerializationFailure
+}
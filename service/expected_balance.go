@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// ExpectedBalanceTracker 按账户跟踪理论余额
+// 理论余额 = 基准余额（最近一次重置的值）+ 基准之后所有成功扣款和入账的净变化。
+// 成功的变更在策略返回后才计入，正在执行的请求可能已经写入数据库但尚未计入，
+// 因此高并发时实际余额与理论余额会有短暂的、方向相反的偏差，请求结束后即消失
+type ExpectedBalanceTracker struct {
+	mu       sync.Mutex
+	accounts map[int64]*expectedBalance
+}
+
+// expectedBalance 单个账户的理论余额
+type expectedBalance struct {
+	baseline int64 // 基准余额
+	delta    int64 // 基准之后成功扣款（负）和入账（正）的金额之和
+}
+
+// BalanceDrift 实际余额与理论余额的对比
+type BalanceDrift struct {
+	UserID          int64 `json:"user_id"`
+	ActualBalance   int64 `json:"actual_balance"`   // 数据库中的余额（分）
+	ExpectedBalance int64 `json:"expected_balance"` // 理论余额（分）
+	HeldBalance     int64 `json:"held_balance"`     // 冻结金额（分）
+	Available       int64 `json:"available"`        // 可用余额（分），为负说明发生了超额授权
+	LostAmount      int64 `json:"lost_amount"`      // 实际余额 - 理论余额，大于 0 表示有扣款被覆盖（余额凭空变多），小于 0 表示有入账被覆盖（分）
+}
+
+// NewExpectedBalanceTracker 创建理论余额跟踪器
+func NewExpectedBalanceTracker() *ExpectedBalanceTracker {
+	return &ExpectedBalanceTracker{
+		accounts: make(map[int64]*expectedBalance),
+	}
+}
+
+// Reset 以 balance 为基准重新开始跟踪
+func (t *ExpectedBalanceTracker) Reset(userID int64, balance int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accounts[userID] = &expectedBalance{baseline: balance}
+}
+
+// RecordChange 计入一次成功的余额变更，delta 扣款为负、入账为正
+// 账户尚未被跟踪时，以本次请求读到的余额 oldBalance 作为基准
+func (t *ExpectedBalanceTracker) RecordChange(userID int64, oldBalance int64, delta int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.accounts[userID]
+	if !ok {
+		entry = &expectedBalance{baseline: oldBalance}
+		t.accounts[userID] = entry
+	}
+	entry.delta += delta
+}
+
+// Expected 返回理论余额；账户尚未被跟踪时以 actual 作为基准开始跟踪
+func (t *ExpectedBalanceTracker) Expected(userID int64, actual int64) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.accounts[userID]
+	if !ok {
+		entry = &expectedBalance{baseline: actual}
+		t.accounts[userID] = entry
+	}
+	return entry.baseline + entry.delta
+}
+
+// Deduct 使用指定策略扣款（req.Credit 为 true 时入账），成功后计入理论余额
+// handler 应通过本方法而不是直接调用 strategy.Deduct，否则理论余额无法反映这次变更
+func (s *AccountService) Deduct(ctx context.Context, strategy DeductStrategy, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	resp, err := strategy.Deduct(ctx, req, requestID)
+	if err != nil {
+		return nil, err
+	}
+	s.expected.RecordChange(req.UserID, resp.OldBalance, req.delta())
+	return resp, nil
+}
+
+// Credit 使用指定策略入账，与扣款走同一套并发控制
+func (s *AccountService) Credit(ctx context.Context, strategy DeductStrategy, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	req.Credit = true
+	return s.Deduct(ctx, strategy, req, requestID)
+}
+
+// BalanceDrift 读取账户的实际余额并与理论余额对比
+func (s *AccountService) BalanceDrift(ctx context.Context, userID int64) (*BalanceDrift, error) {
+	account, err := s.getAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := s.expected.Expected(userID, account.Balance)
+	return &BalanceDrift{
+		UserID:          userID,
+		ActualBalance:   account.Balance,
+		ExpectedBalance: expected,
+		HeldBalance:     account.HeldBalance,
+		Available:       account.Available(),
+		LostAmount:      account.Balance - expected,
+	}, nil
+}
+
+// BalanceInvariant 全部账户余额之和与理论总额的对比
+// 转账只在账户之间搬运金额，不改变总额；总额出现偏差说明有钱被凭空创造或销毁
+type BalanceInvariant struct {
+	AccountCount   int   `json:"account_count"`
+	TotalBalance   int64 `json:"total_balance"`   // 数据库中全部账户余额之和（分）
+	ExpectedTotal  int64 `json:"expected_total"`  // 全部账户理论余额之和（分）
+	Drift          int64 `json:"drift"`           // 实际总额 - 理论总额，大于 0 表示钱被凭空创造，小于 0 表示被销毁（分）
+	TotalHeld      int64 `json:"total_held"`      // 全部账户冻结金额之和（分）
+	OverAuthorized int   `json:"over_authorized"` // 可用余额低于策略下限的账户数，未设置透支额度和最低余额时下限为 0
+}
+
+// CheckBalanceInvariant 检查"余额总和守恒"：实际总额应等于基准总额加上全部成功扣款、入账的净变化
+func (s *AccountService) CheckBalanceInvariant(ctx context.Context) (*BalanceInvariant, error) {
+	accounts, err := s.repo.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &BalanceInvariant{AccountCount: len(accounts)}
+	for _, account := range accounts {
+		inv.TotalBalance += account.Balance
+		inv.ExpectedTotal += s.expected.Expected(account.UserID, account.Balance)
+		inv.TotalHeld += account.HeldBalance
+		if account.Available() < account.Policy.Floor() {
+			inv.OverAuthorized++
+		}
+	}
+	inv.Drift = inv.TotalBalance - inv.ExpectedTotal
+	return inv, nil
+}
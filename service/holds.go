@@ -0,0 +1,784 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+// 预授权冻结默认配置，config.yaml 未配置时使用
+const (
+	defaultHoldTTLSec        = 300
+	defaultMaxHoldTTLSec     = 86400
+	defaultHoldSweepInterval = 5
+	defaultHoldSweepBatch    = 100
+	defaultHoldPageSize      = 50
+	maxHoldPageSize          = 1000
+)
+
+var (
+	// ErrHoldNotActive 冻结已被扣款、撤销或过期
+	ErrHoldNotActive = errors.New("hold is not active")
+	// ErrHoldExpired 冻结已过期，等待后台任务释放
+	ErrHoldExpired = errors.New("hold expired")
+	// ErrCaptureExceedsHold 扣款金额超过冻结金额
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds hold amount")
+	// ErrInvalidHoldTTL 有效期超出允许范围
+	ErrInvalidHoldTTL = errors.New("invalid hold ttl")
+	// ErrHoldUnsupported 指定的策略不支持冻结和扣款
+	ErrHoldUnsupported = errors.New("strategy does not support holds")
+)
+
+// errHoldCASFailed 乐观锁冻结时版本号不匹配，用于回滚后重试
+var errHoldCASFailed = errors.New("hold compare-and-swap failed")
+
+// holdSettings 读取预授权冻结配置，未配置的项使用默认值
+func holdSettings() config.HoldsConfig {
+	settings := config.HoldsConfig{
+		DefaultTTLSec:    defaultHoldTTLSec,
+		MaxTTLSec:        defaultMaxHoldTTLSec,
+		SweepIntervalSec: defaultHoldSweepInterval,
+		SweepBatchSize:   defaultHoldSweepBatch,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Holds.DefaultTTLSec > 0 {
+		settings.DefaultTTLSec = cfg.Holds.DefaultTTLSec
+	}
+	if cfg.Holds.MaxTTLSec > 0 {
+		settings.MaxTTLSec = cfg.Holds.MaxTTLSec
+	}
+	if cfg.Holds.SweepIntervalSec > 0 {
+		settings.SweepIntervalSec = cfg.Holds.SweepIntervalSec
+	}
+	if cfg.Holds.SweepBatchSize > 0 {
+		settings.SweepBatchSize = cfg.Holds.SweepBatchSize
+	}
+	return settings
+}
+
+// HoldRequest 冻结请求
+type HoldRequest struct {
+	UserID   int64  `json:"user_id" binding:"required"`
+	Amount   int64  `json:"amount" binding:"required"` // 单位：分
+	TTLSec   int    `json:"ttl_sec"`                   // 有效期，为 0 时使用 holds.default_ttl_sec
+	Strategy string `json:"strategy"`                  // 并发控制策略，为空时使用当前扣款策略
+}
+
+// CaptureRequest 扣款请求
+type CaptureRequest struct {
+	Amount   int64  `json:"amount"`   // 扣款金额，为 0 时扣除全部冻结金额，不足部分随扣款一起释放
+	Strategy string `json:"strategy"` // 并发控制策略，为空时使用当前扣款策略
+}
+
+// HoldResponse 冻结、扣款或撤销后的结果
+type HoldResponse struct {
+	RequestID   string      `json:"request_id"`
+	Hold        *model.Hold `json:"hold"`
+	OldBalance  int64       `json:"old_balance"`  // 操作前余额（分）
+	Balance     int64       `json:"balance"`      // 操作后余额（分）
+	HeldBalance int64       `json:"held_balance"` // 操作后冻结金额（分）
+	Available   int64       `json:"available"`    // 操作后可用余额（分），并发冻结没有保护时可能为负
+	Strategy    string      `json:"strategy,omitempty"`
+	Retries     int         `json:"retries"`
+	ErrorClass  string      `json:"error_class,omitempty"`
+	Timeline    Timeline    `json:"timeline"`
+}
+
+// HoldPage 一页冻结记录
+type HoldPage struct {
+	Items      []model.Hold `json:"items"`
+	NextCursor int64        `json:"next_cursor,omitempty"` // 下一页的游标，为 0 表示没有更多
+	HasMore    bool         `json:"has_more"`
+}
+
+// heldChange 一次冻结相关操作对账户的修改
+// 余额和冻结金额都按增量写入，由策略保证"检查账户策略"和"写入"之间没有其他请求插入
+type heldChange struct {
+	userID  int64
+	balance int64 // 余额增量
+	held    int64 // 冻结金额增量
+	check   bool  // 是否要求修改后可用余额不低于策略下限（冻结时）
+	spend   int64 // 受单笔上限和累计上限约束的金额：冻结为冻结金额，扣款为扣款金额
+	// record 与账户修改在同一事务内执行，写入冻结记录和流水；account 为修改前读到的账户
+	record func(tx repository.AccountRepository, account *model.Account, timeline *Timeline) error
+}
+
+// heldResult 执行 heldChange 的结果
+type heldResult struct {
+	before   *model.Account // 修改前的账户
+	retries  int
+	timeline Timeline
+}
+
+// verify 检查账户未关闭，并按账户策略检查本次修改：单笔上限、修改后可用余额的下限（冻结时）和最近 24 小时的累计上限
+// 冻结时检查累计上限是为了提前拒绝扣款时必然超限的冻结；repo 须与读取 account 处于同一把锁或同一个事务内
+func (c *heldChange) verify(ctx context.Context, repo repository.AccountRepository, account *model.Account) error {
+	if account.Closed() {
+		return ErrAccountClosed
+	}
+	if c.check {
+		if err := checkPolicy(account.Policy, account.Available(), c.held-c.balance); err != nil {
+			return err
+		}
+	} else if err := checkSingleAmount(account.Policy, c.spend); err != nil {
+		return err
+	}
+	if c.spend <= 0 {
+		return nil
+	}
+	spent, err := dailySpent(ctx, repo, c.userID, account.Policy)
+	if err != nil {
+		return err
+	}
+	return checkDailyLimit(account.Policy, spent, c.spend)
+}
+
+// Authorize 使用指定策略冻结金额：通过账户策略检查时计入 held_balance 并创建冻结记录
+// 冻结后可用余额不能低于策略下限，冻结金额受单笔上限约束，且加上最近 24 小时的扣款不能超过累计上限
+// 检查可用余额和写入之间没有并发保护时（unlocked），并行的冻结会同时通过检查，冻结总额超过余额（超额授权）
+func (s *AccountService) Authorize(ctx context.Context, strategy string, req *HoldRequest, requestID string) (*HoldResponse, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	settings := holdSettings()
+	ttlSec := req.TTLSec
+	if ttlSec == 0 {
+		ttlSec = settings.DefaultTTLSec
+	}
+	if ttlSec < 0 || ttlSec > settings.MaxTTLSec {
+		return nil, fmt.Errorf("%w: must be between 1 and %d seconds", ErrInvalidHoldTTL, settings.MaxTTLSec)
+	}
+
+	hold := &model.Hold{
+		UserID:    req.UserID,
+		Amount:    req.Amount,
+		Status:    model.HoldStatusActive,
+		RequestID: requestID,
+		Strategy:  strategy,
+	}
+	change := &heldChange{
+		userID: req.UserID,
+		held:   req.Amount,
+		check:  true,
+		spend:  req.Amount,
+		record: func(tx repository.AccountRepository, account *model.Account, timeline *Timeline) error {
+			// 乐观锁重试时会重新创建
+			hold.ID = 0
+			hold.ExpiresAt = time.Now().Add(time.Duration(ttlSec) * time.Second)
+			if err := tx.CreateHold(ctx, hold); err != nil {
+				return fmt.Errorf("failed to create hold: %w", err)
+			}
+			return nil
+		},
+	}
+
+	result, err := s.applyHeldChange(ctx, strategy, requestID, change)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[%s] 🧊 [HOLD] 冻结 #%d 成功，user_id=%d amount=%d", requestID, hold.ID, req.UserID, req.Amount)
+	return newHoldResponse(requestID, strategy, hold, change, result), nil
+}
+
+// Capture 使用指定策略对冻结扣款：余额减少扣款金额，冻结金额减少整笔冻结，成功后计入理论余额
+// 扣款动用的是已冻结的金额，不再检查余额下限，但与普通扣款一样受单笔上限和累计上限约束
+// 冻结状态在同一事务内从 active 改为 captured，同一笔冻结并发扣款时只有一个成功
+func (s *AccountService) Capture(ctx context.Context, strategy string, holdID int64, req *CaptureRequest, requestID string) (*HoldResponse, error) {
+	hold, err := s.repo.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != model.HoldStatusActive {
+		return nil, ErrHoldNotActive
+	}
+	if hold.Expired(time.Now()) {
+		return nil, ErrHoldExpired
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+	if amount > hold.Amount {
+		return nil, ErrCaptureExceedsHold
+	}
+
+	change := &heldChange{
+		userID:  hold.UserID,
+		balance: -amount,
+		held:    -hold.Amount,
+		spend:   amount,
+		record: func(tx repository.AccountRepository, account *model.Account, timeline *Timeline) error {
+			// 开头的检查基于锁外读到的副本，这里在账户行锁内重新确认：
+			// 过期释放同样先锁账户行，已过期的冻结只能由它释放，不会在到期后仍被扣款
+			current, err := tx.GetHold(ctx, hold.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get hold: %w", err)
+			}
+			if current.Status != model.HoldStatusActive {
+				return ErrHoldNotActive
+			}
+			if current.Expired(time.Now()) {
+				return ErrHoldExpired
+			}
+			finished, err := tx.FinishHold(ctx, hold.ID, model.HoldStatusCaptured, amount)
+			if err != nil {
+				return fmt.Errorf("failed to update hold: %w", err)
+			}
+			if !finished {
+				return ErrHoldNotActive
+			}
+			txn := &model.Transaction{
+				RequestID:     requestID,
+				UserID:        hold.UserID,
+				Type:          model.TransactionTypeCapture,
+				Amount:        amount,
+				BalanceBefore: account.Balance,
+				BalanceAfter:  account.Balance - amount,
+				Strategy:      strategy,
+				ReadStart:     timeline.ReadStart,
+				ReadEnd:       timeline.ReadEnd,
+				ComputeStart:  timeline.ComputeStart,
+				ComputeEnd:    timeline.ComputeEnd,
+				WriteStart:    timeline.WriteStart,
+				WriteEnd:      timeline.WriteEnd,
+			}
+			if err := tx.AppendTransaction(ctx, txn); err != nil {
+				return fmt.Errorf("failed to append transaction: %w", err)
+			}
+			return nil
+		},
+	}
+
+	result, err := s.applyHeldChange(ctx, strategy, requestID, change)
+	if err != nil {
+		return nil, err
+	}
+	s.expected.RecordChange(hold.UserID, result.before.Balance, -amount)
+
+	hold.Status = model.HoldStatusCaptured
+	hold.CapturedAmount = amount
+	log.Printf("[%s] 🧊 [HOLD] 冻结 #%d 扣款 %d分", requestID, hold.ID, amount)
+	return newHoldResponse(requestID, strategy, hold, change, result), nil
+}
+
+// Void 撤销冻结，释放冻结金额
+// 释放只会增加可用余额，不会导致超额授权，因此不经过策略，直接在一个事务内完成
+func (s *AccountService) Void(ctx context.Context, holdID int64, requestID string) (*HoldResponse, error) {
+	hold, err := s.repo.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.releaseHold(ctx, hold, model.HoldStatusVoided)
+	if err != nil {
+		return nil, err
+	}
+	resp.RequestID = requestID
+	log.Printf("[%s] 🧊 [HOLD] 冻结 #%d 已撤销", requestID, hold.ID)
+	return resp, nil
+}
+
+// ExpireHolds 释放所有已过期仍未扣款的冻结，返回释放的数量
+func (s *AccountService) ExpireHolds(ctx context.Context) (int, error) {
+	settings := holdSettings()
+	now := time.Now()
+
+	expired := 0
+	var afterID int64
+	for {
+		holds, err := s.repo.FindHolds(ctx, repository.HoldFilter{
+			Status:        model.HoldStatusActive,
+			ExpiresBefore: &now,
+			AfterID:       afterID,
+			Limit:         settings.SweepBatchSize,
+		})
+		if err != nil {
+			return expired, err
+		}
+		for i := range holds {
+			_, err := s.releaseHold(ctx, &holds[i], model.HoldStatusExpired)
+			if errors.Is(err, ErrHoldNotActive) {
+				// 期间已被扣款或撤销
+				continue
+			}
+			if err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(holds) < settings.SweepBatchSize {
+			return expired, nil
+		}
+		afterID = holds[len(holds)-1].ID
+	}
+}
+
+// releaseHold 把冻结改为 status 并释放冻结金额，两者在同一事务内完成
+func (s *AccountService) releaseHold(ctx context.Context, hold *model.Hold, status string) (*HoldResponse, error) {
+	if hold.Status != model.HoldStatusActive {
+		return nil, ErrHoldNotActive
+	}
+
+	// 与扣款相同，先写账户行再写冻结行，避免两者并发时加锁顺序相反导致死锁
+	var account *model.Account
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		if _, err := tx.AdjustHeldBalance(ctx, hold.UserID, repository.HeldAdjustment{Held: -hold.Amount}); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		finished, err := tx.FinishHold(ctx, hold.ID, status, 0)
+		if err != nil {
+			return fmt.Errorf("failed to update hold: %w", err)
+		}
+		if !finished {
+			return ErrHoldNotActive
+		}
+		account, err = tx.GetAccount(ctx, hold.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = status
+	return &HoldResponse{
+		Hold:        hold,
+		OldBalance:  account.Balance,
+		Balance:     account.Balance,
+		HeldBalance: account.HeldBalance,
+		Available:   account.Available(),
+	}, nil
+}
+
+// GetHold 查询冻结记录
+func (s *AccountService) GetHold(ctx context.Context, holdID int64) (*model.Hold, error) {
+	return s.repo.GetHold(ctx, holdID)
+}
+
+// ListHolds 按 ID 升序分页查询账户的冻结记录，status 为空时不限状态
+func (s *AccountService) ListHolds(ctx context.Context, userID int64, status string, cursor int64, limit int) (*HoldPage, error) {
+	if limit <= 0 {
+		limit = defaultHoldPageSize
+	}
+	if limit > maxHoldPageSize {
+		limit = maxHoldPageSize
+	}
+
+	// 多取一条用于判断是否还有下一页
+	holds, err := s.repo.FindHolds(ctx, repository.HoldFilter{
+		UserID:  userID,
+		Status:  status,
+		AfterID: cursor,
+		Limit:   limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &HoldPage{Items: holds}
+	if len(holds) > limit {
+		page.Items = holds[:limit]
+		page.HasMore = true
+		page.NextCursor = page.Items[limit-1].ID
+	}
+	if page.Items == nil {
+		page.Items = []model.Hold{}
+	}
+	return page, nil
+}
+
+// newHoldResponse 根据修改前的账户和增量计算操作后的余额
+func newHoldResponse(requestID, strategy string, hold *model.Hold, change *heldChange, result *heldResult) *HoldResponse {
+	balance := result.before.Balance + change.balance
+	held := result.before.HeldBalance + change.held
+	resp := &HoldResponse{
+		RequestID:   requestID,
+		Hold:        hold,
+		OldBalance:  result.before.Balance,
+		Balance:     balance,
+		HeldBalance: held,
+		Available:   balance - held,
+		Strategy:    strategy,
+		Retries:     result.retries,
+		Timeline:    result.timeline,
+	}
+	if result.retries > 0 {
+		resp.ErrorClass = ErrorClassVersionConflict
+	}
+	return resp
+}
+
+// applyHeldChange 按策略的并发控制执行 change
+// 与扣款使用同一套锁，冻结、扣款和普通扣款之间同样互斥
+func (s *AccountService) applyHeldChange(ctx context.Context, strategy, requestID string, change *heldChange) (*heldResult, error) {
+	switch strategy {
+	case StrategyUnlocked:
+		return s.heldInCriticalSection(ctx, change, requestID, "🧊 [HOLD]", nil)
+	case StrategyMutex:
+		lockWaitStart := time.Now().UnixNano()
+		unlock, _ := s.accountLocks.Lock(change.userID)
+		defer unlock()
+		lockWaitEnd := time.Now().UnixNano()
+
+		result, err := s.heldInCriticalSection(ctx, change, requestID, "🔒 [HOLD]", nil)
+		if err != nil {
+			return nil, err
+		}
+		result.timeline.LockWaitStart = lockWaitStart
+		result.timeline.LockWaitEnd = lockWaitEnd
+		return result, nil
+	case StrategyAdvisory:
+		return s.heldWithAdvisoryLock(ctx, change, requestID)
+	case StrategyRedis:
+		return s.heldWithRedisLock(ctx, change, requestID)
+	case StrategyPessimistic:
+		return s.heldForUpdate(ctx, change, requestID)
+	case StrategyOptimistic:
+		return s.heldOptimistic(ctx, change, requestID)
+	case StrategyAtomic:
+		return s.heldAtomic(ctx, change, requestID)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrHoldUnsupported, strategy)
+	}
+}
+
+// heldWithAdvisoryLock 持有账户的数据库命名锁执行 change
+func (s *AccountService) heldWithAdvisoryLock(ctx context.Context, change *heldChange, requestID string) (*heldResult, error) {
+	settings := advisoryLockSettings()
+
+	var result *heldResult
+	lockWaitStart := time.Now().UnixNano()
+	err := s.repo.WithAdvisoryLock(ctx, advisoryLockName(change.userID), time.Duration(settings.TimeoutSec)*time.Second, func() error {
+		lockWaitEnd := time.Now().UnixNano()
+
+		var err error
+		result, err = s.heldInCriticalSection(ctx, change, requestID, "🗝️ [HOLD]", nil)
+		if err != nil {
+			return err
+		}
+		result.timeline.LockWaitStart = lockWaitStart
+		result.timeline.LockWaitEnd = lockWaitEnd
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// heldWithRedisLock 持有账户的 Redis 分布式锁执行 change，写入前确认租约仍然有效
+func (s *AccountService) heldWithRedisLock(ctx context.Context, change *heldChange, requestID string) (*heldResult, error) {
+	locker, err := s.getRedisLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s%d", redisLockSettings().KeyPrefix, change.userID)
+
+	lockWaitStart := time.Now().UnixNano()
+	lock, err := locker.Acquire(ctx, key)
+	lockWaitEnd := time.Now().UnixNano()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			log.Printf("[%s] 🌐 [HOLD] 释放锁 %s 失败: %v", requestID, key, err)
+		}
+	}()
+
+	result, err := s.heldInCriticalSection(ctx, change, requestID, "🌐 [HOLD]", func() error {
+		if lock.Lost() {
+			return ErrLockLost
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.timeline.LockWaitStart = lockWaitStart
+	result.timeline.LockWaitEnd = lockWaitEnd
+	return result, nil
+}
+
+// heldInCriticalSection 在调用方已持有锁（或故意不加锁）的前提下执行"读取-检查-写入"
+// 不加锁时多个冻结会基于同一份可用余额通过检查，增量写入不会丢失，但冻结总额会超过余额
+func (s *AccountService) heldInCriticalSection(ctx context.Context, change *heldChange, requestID, tag string, beforeWrite func() error) (*heldResult, error) {
+	var timeline Timeline
+
+	// 步骤1: 读取余额和冻结金额
+	timeline.ReadStart = time.Now().UnixNano()
+	account, err := s.getAccount(ctx, change.userID)
+	timeline.ReadEnd = time.Now().UnixNano()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[%s] %s Step 1: 余额=%d分，冻结=%d分，可用=%d分", requestID, tag, account.Balance, account.HeldBalance, account.Available())
+
+	// 步骤2: 按账户策略检查
+	if err := change.verify(ctx, s.repo, account); err != nil {
+		return nil, err
+	}
+
+	// 步骤3: 计算阶段（与扣款保持相同的业务延迟，方便对比）
+	timeline.ComputeStart = time.Now().UnixNano()
+	time.Sleep(10 * time.Millisecond)
+	timeline.ComputeEnd = time.Now().UnixNano()
+
+	if beforeWrite != nil {
+		if err := beforeWrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 步骤4: 确认账户未关闭后增量写入并记录冻结
+	err = s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		timeline.WriteStart = time.Now().UnixNano()
+		if err := ensureActive(ctx, tx, change.userID); err != nil {
+			return err
+		}
+		if _, err := tx.AdjustHeldBalance(ctx, change.userID, repository.HeldAdjustment{Balance: change.balance, Held: change.held}); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		timeline.WriteEnd = time.Now().UnixNano()
+		return change.record(tx, account, &timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &heldResult{before: account, timeline: timeline}, nil
+}
+
+// heldForUpdate 悲观锁版本：事务内 SELECT ... FOR UPDATE 锁住账户行后检查并写入
+func (s *AccountService) heldForUpdate(ctx context.Context, change *heldChange, requestID string) (*heldResult, error) {
+	settings := pessimisticSettings()
+	isolation, err := parseIsolationLevel(settings.IsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &heldResult{}
+	txOpts := &repository.TxOptions{
+		Isolation:       isolation,
+		LockWaitTimeout: time.Duration(settings.LockWaitTimeoutSec) * time.Second,
+	}
+	err = s.repo.Transaction(ctx, txOpts, func(tx repository.AccountRepository) error {
+		timeline := &result.timeline
+		timeline.LockWaitStart = time.Now().UnixNano()
+		account, err := tx.GetAccountForUpdate(ctx, change.userID)
+		timeline.LockWaitEnd = time.Now().UnixNano()
+		timeline.ReadStart = timeline.LockWaitStart
+		timeline.ReadEnd = timeline.LockWaitEnd
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		log.Printf("[%s] 🔐 [HOLD] 锁定账户 user_id=%d，可用=%d分", requestID, change.userID, account.Available())
+
+		if err := change.verify(ctx, tx, account); err != nil {
+			return err
+		}
+
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		timeline.WriteStart = time.Now().UnixNano()
+		if _, err := tx.AdjustHeldBalance(ctx, change.userID, repository.HeldAdjustment{Balance: change.balance, Held: change.held}); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		timeline.WriteEnd = time.Now().UnixNano()
+
+		result.before = account
+		return change.record(tx, account, timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// heldOptimistic 乐观锁版本：带版本号条件写入，版本号不匹配时回滚并退避重试
+func (s *AccountService) heldOptimistic(ctx context.Context, change *heldChange, requestID string) (*heldResult, error) {
+	settings := optimisticSettings()
+
+	for attempt := 1; attempt <= settings.MaxAttempts; attempt++ {
+		var timeline Timeline
+
+		timeline.ReadStart = time.Now().UnixNano()
+		account, err := s.getAccount(ctx, change.userID)
+		timeline.ReadEnd = time.Now().UnixNano()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := change.verify(ctx, s.repo, account); err != nil {
+			return nil, err
+		}
+
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		timeline.WriteStart = time.Now().UnixNano()
+		err = s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+			swapped, err := tx.AdjustHeldBalance(ctx, change.userID, repository.HeldAdjustment{
+				Balance:         change.balance,
+				Held:            change.held,
+				ExpectedVersion: &account.Version,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update balance: %w", err)
+			}
+			if !swapped {
+				return errHoldCASFailed
+			}
+			if change.spend > 0 {
+				if err := recheckDailyLimit(ctx, tx, account, change.spend); err != nil {
+					return err
+				}
+			}
+			timeline.WriteEnd = time.Now().UnixNano()
+			return change.record(tx, account, &timeline)
+		})
+		if err == nil {
+			return &heldResult{before: account, retries: attempt - 1, timeline: timeline}, nil
+		}
+		if !errors.Is(err, errHoldCASFailed) {
+			return nil, err
+		}
+
+		log.Printf("[%s] 🔁 [HOLD #%d] CAS 失败，version=%d 已被其他请求修改", requestID, attempt, account.Version)
+		if attempt == settings.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(optimisticBackoff(settings, attempt)):
+		}
+	}
+
+	return nil, &RetryError{
+		Retries: settings.MaxAttempts - 1,
+		Class:   ErrorClassVersionConflict,
+		Err:     ErrOptimisticConflict,
+	}
+}
+
+// heldAtomic 原子条件更新版本：UPDATE ... WHERE balance - held_balance - ? >= min_balance - overdraft_limit，检查和写入在一条语句内完成
+func (s *AccountService) heldAtomic(ctx context.Context, change *heldChange, requestID string) (*heldResult, error) {
+	result := &heldResult{}
+
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		start := time.Now().UnixNano()
+		applied, err := tx.AdjustHeldBalance(ctx, change.userID, repository.HeldAdjustment{
+			Balance:          change.balance,
+			Held:             change.held,
+			RequireAvailable: change.check,
+		})
+		end := time.Now().UnixNano()
+		result.timeline.ReadStart, result.timeline.ReadEnd = start, end
+		result.timeline.ComputeStart, result.timeline.ComputeEnd = start, end
+		result.timeline.WriteStart, result.timeline.WriteEnd = start, end
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+
+		// 回读：既用于区分"账户不存在"和"可用余额不足"，也用于推算修改前的账户
+		account, err := tx.GetAccount(ctx, change.userID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		if !applied {
+			// 条件不满足时按回读到的账户重新判定，给出具体违反的策略
+			if err := change.verify(ctx, tx, account); err != nil {
+				return err
+			}
+			return ErrInsufficientBalance
+		}
+		account.Balance -= change.balance
+		account.HeldBalance -= change.held
+
+		// 余额下限已由条件 UPDATE 保证，单笔上限和累计上限在同一事务内持有行锁时检查，违反则整体回滚
+		if err := change.verify(ctx, tx, account); err != nil {
+			return err
+		}
+		log.Printf("[%s] ⚛️ [HOLD] 条件更新成功，可用=%d分", requestID, account.Available()+change.balance-change.held)
+
+		result.before = account
+		return change.record(tx, account, &result.timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HoldSweeper 后台释放过期冻结
+type HoldSweeper struct {
+	svc *AccountService
+
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewHoldSweeper 创建过期冻结释放任务
+func NewHoldSweeper(svc *AccountService) *HoldSweeper {
+	return &HoldSweeper{svc: svc}
+}
+
+// Start 按 holds.sweep_interval_sec 启动后台任务
+func (h *HoldSweeper) Start() {
+	h.stopChan = make(chan struct{})
+	h.done = make(chan struct{})
+	interval := time.Duration(holdSettings().SweepIntervalSec) * time.Second
+
+	go func() {
+		defer close(h.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("过期冻结释放任务已启动（%v 间隔）", interval)
+		for {
+			select {
+			case <-h.stopChan:
+				log.Println("过期冻结释放任务已停止")
+				return
+			case <-ticker.C:
+				expired, err := h.svc.ExpireHolds(context.Background())
+				if err != nil {
+					log.Printf("释放过期冻结失败: %v", err)
+				}
+				if expired > 0 {
+					log.Printf("🧊 已释放 %d 笔过期冻结", expired)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台任务并等待当前一轮完成
+func (h *HoldSweeper) Stop() {
+	if h.stopChan == nil {
+		return
+	}
+	close(h.stopChan)
+	<-h.done
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+// holdStrategies 支持冻结和扣款且有并发保护的策略；unlocked 故意允许超额授权，不在这里测试
+var holdStrategies = []string{
+	StrategyMutex,
+	StrategyPessimistic,
+	StrategyOptimistic,
+	StrategyAtomic,
+	StrategyAdvisory,
+	StrategyRedis,
+}
+
+// createTestHold 直接在存储中创建一笔指定过期时间的冻结，用于构造即将过期的冻结
+func createTestHold(t *testing.T, repo repository.AccountRepository, userID, amount int64, expiresAt time.Time) *model.Hold {
+	t.Helper()
+	ctx := context.Background()
+	hold := &model.Hold{
+		UserID:    userID,
+		Amount:    amount,
+		Status:    model.HoldStatusActive,
+		RequestID: fmt.Sprintf("hold-%d", userID),
+		Strategy:  "test",
+		ExpiresAt: expiresAt,
+	}
+	err := repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		if _, err := tx.AdjustHeldBalance(ctx, userID, repository.HeldAdjustment{Held: amount}); err != nil {
+			return err
+		}
+		return tx.CreateHold(ctx, hold)
+	})
+	if err != nil {
+		t.Fatalf("create hold: %v", err)
+	}
+	return hold
+}
+
+// accountOf 读取账户
+func accountOf(t *testing.T, repo repository.AccountRepository, userID int64) *model.Account {
+	t.Helper()
+	account, err := repo.GetAccount(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	return account
+}
+
+func TestAuthorizeConcurrentNeverOverHolds(t *testing.T) {
+	useTestConfig(t, &config.Config{Strategy: config.StrategyConfig{
+		Optimistic: config.OptimisticConfig{MaxAttempts: 100, BackoffMs: 1, MaxBackoffMs: 20},
+	}})
+
+	const (
+		userID  int64 = 1
+		initial int64 = 1000
+		amount  int64 = 100
+		n             = 20 // 请求总额是余额的两倍
+	)
+	for _, strategy := range holdStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			svc, repo := newTestService(t)
+			createTestAccount(t, svc, userID, initial)
+
+			var (
+				mu       sync.Mutex
+				accepted int
+				errs     []error
+				wg       sync.WaitGroup
+			)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					req := &HoldRequest{UserID: userID, Amount: amount, TTLSec: 60}
+					_, err := svc.Authorize(context.Background(), strategy, req, fmt.Sprintf("%s-%d", strategy, i))
+					mu.Lock()
+					defer mu.Unlock()
+					if err == nil {
+						accepted++
+					} else if !errors.Is(err, ErrInsufficientBalance) {
+						errs = append(errs, err)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if len(errs) > 0 {
+				t.Fatalf("%d authorizes failed unexpectedly, first error: %v", len(errs), errs[0])
+			}
+
+			account := accountOf(t, repo, userID)
+			if account.HeldBalance > account.Balance {
+				t.Errorf("held_balance = %d exceeds balance %d", account.HeldBalance, account.Balance)
+			}
+			// 余额恰好够 initial/amount 笔，多出的请求必须被拒绝
+			if want := int(initial / amount); accepted != want {
+				t.Errorf("accepted = %d, want %d", accepted, want)
+			}
+			if account.HeldBalance != int64(accepted)*amount {
+				t.Errorf("held_balance = %d, want %d (accepted=%d)", account.HeldBalance, int64(accepted)*amount, accepted)
+			}
+			holds, err := repo.FindHolds(context.Background(), repository.HoldFilter{UserID: userID, Status: model.HoldStatusActive, Limit: n})
+			if err != nil {
+				t.Fatalf("find holds: %v", err)
+			}
+			if len(holds) != accepted {
+				t.Errorf("active holds = %d, want %d", len(holds), accepted)
+			}
+		})
+	}
+}
+
+func TestCaptureExpiredWhileWaitingForLock(t *testing.T) {
+	const (
+		userID  int64 = 1
+		initial int64 = 1000
+		amount  int64 = 300
+	)
+	svc, repo := newTestService(t)
+	createTestAccount(t, svc, userID, initial)
+	hold := createTestHold(t, repo, userID, amount, time.Now().Add(50*time.Millisecond))
+
+	// 扣款在锁外检查时冻结尚未过期，拿到锁时已经过期
+	unlock, _ := svc.accountLocks.Lock(userID)
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.Capture(context.Background(), StrategyMutex, hold.ID, &CaptureRequest{}, "capture")
+		done <- err
+	}()
+	for waiting := 0; waiting < 2; {
+		time.Sleep(time.Millisecond)
+		for _, st := range svc.accountLocks.Stats() {
+			if st.Key == userID {
+				waiting = st.Waiting
+			}
+		}
+	}
+	time.Sleep(time.Until(hold.ExpiresAt))
+	unlock()
+
+	if err := <-done; !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("capture err = %v, want ErrHoldExpired", err)
+	}
+	if expired, err := svc.ExpireHolds(context.Background()); err != nil || expired != 1 {
+		t.Fatalf("expire holds = (%d, %v), want (1, nil)", expired, err)
+	}
+	account := accountOf(t, repo, userID)
+	if account.Balance != initial || account.HeldBalance != 0 {
+		t.Fatalf("account = (balance %d, held %d), want (%d, 0)", account.Balance, account.HeldBalance, initial)
+	}
+}
+
+func TestCaptureVersusSweeperSettlesOnce(t *testing.T) {
+	const (
+		initial    int64 = 1000
+		amount     int64 = 300
+		iterations       = 20
+	)
+	for _, strategy := range holdStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			svc, repo := newTestService(t)
+			ctx := context.Background()
+			captured := 0
+
+			for i := 0; i < iterations; i++ {
+				userID := int64(i + 1)
+				createTestAccount(t, svc, userID, initial)
+				hold := createTestHold(t, repo, userID, amount, time.Now().Add(20*time.Millisecond))
+
+				// 扣款在读到账户后模拟 10ms 计算，随机延迟启动使部分扣款在到期前完成、部分在计算期间到期
+				var (
+					captureErr error
+					expired    int
+					wg         sync.WaitGroup
+				)
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+					_, captureErr = svc.Capture(ctx, strategy, hold.ID, &CaptureRequest{}, fmt.Sprintf("%s-%d", strategy, i))
+				}()
+				go func() {
+					defer wg.Done()
+					deadline := time.Now().Add(time.Second)
+					for time.Now().Before(deadline) {
+						n, err := svc.ExpireHolds(ctx)
+						if err != nil {
+							t.Errorf("expire holds: %v", err)
+							return
+						}
+						expired += n
+						current, err := repo.GetHold(ctx, hold.ID)
+						if err != nil {
+							t.Errorf("get hold: %v", err)
+							return
+						}
+						if current.Status != model.HoldStatusActive {
+							return
+						}
+						time.Sleep(time.Millisecond)
+					}
+				}()
+				wg.Wait()
+
+				current, err := repo.GetHold(ctx, hold.ID)
+				if err != nil {
+					t.Fatalf("get hold: %v", err)
+				}
+				account := accountOf(t, repo, userID)
+				captures := countLedger(t, repo, userID, model.TransactionTypeCapture)
+				switch current.Status {
+				case model.HoldStatusCaptured:
+					captured++
+					if captureErr != nil || expired != 0 {
+						t.Errorf("iteration %d: captured hold with capture err %v and %d expirations", i, captureErr, expired)
+					}
+					if account.Balance != initial-amount || captures != 1 {
+						t.Errorf("iteration %d: captured hold left balance %d and %d capture rows", i, account.Balance, captures)
+					}
+				case model.HoldStatusExpired:
+					if !errors.Is(captureErr, ErrHoldExpired) && !errors.Is(captureErr, ErrHoldNotActive) {
+						t.Errorf("iteration %d: capture err = %v on expired hold", i, captureErr)
+					}
+					if expired != 1 {
+						t.Errorf("iteration %d: expirations = %d, want 1", i, expired)
+					}
+					if account.Balance != initial || captures != 0 {
+						t.Errorf("iteration %d: expired hold left balance %d and %d capture rows", i, account.Balance, captures)
+					}
+				default:
+					t.Fatalf("iteration %d: hold status = %q, want captured or expired", i, current.Status)
+				}
+				// 扣款和释放只能有一个生效，冻结金额不会被释放两次
+				if account.HeldBalance != 0 {
+					t.Errorf("iteration %d: held_balance = %d, want 0", i, account.HeldBalance)
+				}
+			}
+			t.Logf("%s: %d of %d holds captured before expiry", strategy, captured, iterations)
+		})
+	}
+}
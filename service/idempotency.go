@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+var (
+	// ErrIdempotencyKeyReused 同一个幂等键被用于请求体不同的请求
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+	// ErrIdempotencyInProgress 相同幂等键的请求仍在处理中，等待超时
+	ErrIdempotencyInProgress = errors.New("request with the same idempotency key is still in progress")
+)
+
+// 幂等键默认配置，config.yaml 未配置时使用
+const (
+	defaultIdempotencyStore         = "memory"
+	defaultIdempotencyTTLSec        = 86400
+	defaultIdempotencyLeaseSec      = 30
+	defaultIdempotencyWaitTimeoutMs = 5000
+	defaultIdempotencyKeyPrefix     = "zbl:idem:"
+	idempotencyPollInterval         = 20 * time.Millisecond
+)
+
+// IdempotencySettings 读取幂等键配置，未配置的项使用默认值
+func IdempotencySettings() config.IdempotencyConfig {
+	settings := config.IdempotencyConfig{
+		Store:         defaultIdempotencyStore,
+		TTLSec:        defaultIdempotencyTTLSec,
+		LeaseSec:      defaultIdempotencyLeaseSec,
+		WaitTimeoutMs: defaultIdempotencyWaitTimeoutMs,
+		KeyPrefix:     defaultIdempotencyKeyPrefix,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Idempotency.Store != "" {
+		settings.Store = cfg.Idempotency.Store
+	}
+	if cfg.Idempotency.TTLSec > 0 {
+		settings.TTLSec = cfg.Idempotency.TTLSec
+	}
+	if cfg.Idempotency.LeaseSec > 0 {
+		settings.LeaseSec = cfg.Idempotency.LeaseSec
+	}
+	if cfg.Idempotency.WaitTimeoutMs > 0 {
+		settings.WaitTimeoutMs = cfg.Idempotency.WaitTimeoutMs
+	}
+	if cfg.Idempotency.KeyPrefix != "" {
+		settings.KeyPrefix = cfg.Idempotency.KeyPrefix
+	}
+	return settings
+}
+
+// IdempotencyService 幂等键处理
+// 第一个请求占用幂等键并执行，完成后保存最终响应；之后相同 key、相同请求的重试直接返回保存的响应。
+// 相同 key 的请求并发到达时，后到的请求等待先到的请求完成再返回其响应，等待超时返回 ErrIdempotencyInProgress。
+// 占用时只给 key 一个短租约，保存最终响应时才延长到 ttl：进程在处理中崩溃时，key 在租约到期后即可重试
+type IdempotencyService struct {
+	store       repository.IdempotencyStore
+	ttl         time.Duration
+	lease       time.Duration
+	waitTimeout time.Duration
+}
+
+// NewIdempotencyService 按配置创建幂等键处理
+func NewIdempotencyService(store repository.IdempotencyStore) *IdempotencyService {
+	settings := IdempotencySettings()
+	return &IdempotencyService{
+		store:       store,
+		ttl:         time.Duration(settings.TTLSec) * time.Second,
+		lease:       time.Duration(settings.LeaseSec) * time.Second,
+		waitTimeout: time.Duration(settings.WaitTimeoutMs) * time.Millisecond,
+	}
+}
+
+// Begin 开始处理带幂等键的请求
+// 返回 nil 表示调用方占用了 key，执行完请求后必须调用 Finish；
+// 返回已完成的记录表示这是一次重试，调用方应直接返回记录中的响应
+func (s *IdempotencyService) Begin(ctx context.Context, key, fingerprint string) (*model.IdempotencyKey, error) {
+	deadline := time.Now().Add(s.waitTimeout)
+	for {
+		existing, err := s.store.Reserve(ctx, key, fingerprint, s.lease)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, nil
+		}
+		if existing.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if existing.Completed {
+			return existing, nil
+		}
+
+		// 先到的请求仍在处理，等它完成（或失败释放 key 后由本请求重新占用）
+		if time.Now().After(deadline) {
+			return nil, ErrIdempotencyInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// Finish 请求执行完毕，保存或释放幂等键，调用方应在 defer 中调用（handler panic 时按 500 处理）
+// 锁冲突、限流和服务端错误（409/429/5xx）说明请求没有产生确定的结果，释放 key 让客户端可以重试；
+// 其他响应（包括余额不足等业务错误）作为最终结果保存
+func (s *IdempotencyService) Finish(key string, statusCode int, body []byte) {
+	// 使用独立的 context：客户端断开时也要保存结果，否则 key 会一直停留在处理中
+	ctx := context.Background()
+
+	var err error
+	if isFinalStatus(statusCode) {
+		err = s.store.Complete(ctx, key, statusCode, body, s.ttl)
+	} else {
+		err = s.store.Release(ctx, key)
+	}
+	if err != nil {
+		log.Printf("保存幂等键 %s 失败: %v", key, err)
+	}
+}
+
+// isFinalStatus 响应是否为可以缓存的最终结果
+func isFinalStatus(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusConflict, statusCode == http.StatusTooManyRequests:
+		return false
+	case statusCode >= http.StatusInternalServerError:
+		return false
+	default:
+		return true
+	}
+}
+
+// IdempotencyFingerprint 计算请求指纹
+// 请求体是 JSON 时先规范化（字段排序、去掉空白），字段顺序不同的相同请求得到相同的指纹
+func IdempotencyFingerprint(method, path string, body []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
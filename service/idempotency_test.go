@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+)
+
+func TestIdempotencyLeaseExpiresWithoutFinish(t *testing.T) {
+	useTestConfig(t, &config.Config{Idempotency: config.IdempotencyConfig{
+		LeaseSec:      1,
+		WaitTimeoutMs: 50,
+	}})
+	idem := NewIdempotencyService(repository.NewMemoryIdempotencyStore())
+	ctx := context.Background()
+	fingerprint := IdempotencyFingerprint(http.MethodPost, "/api/deduct", []byte(`{"user_id":1,"amount":100}`))
+
+	// 第一个请求占用 key 后进程崩溃，没有调用 Finish
+	if replay, err := idem.Begin(ctx, "crash-key", fingerprint); err != nil || replay != nil {
+		t.Fatalf("first begin = (%v, %v), want reservation", replay, err)
+	}
+	if _, err := idem.Begin(ctx, "crash-key", fingerprint); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Fatalf("begin during lease err = %v, want ErrIdempotencyInProgress", err)
+	}
+
+	// 租约到期后重试可以重新占用
+	time.Sleep(1100 * time.Millisecond)
+	if replay, err := idem.Begin(ctx, "crash-key", fingerprint); err != nil || replay != nil {
+		t.Fatalf("begin after lease = (%v, %v), want reservation", replay, err)
+	}
+
+	// 完成后按 ttl 保存，超过租约仍然返回保存的响应
+	idem.Finish("crash-key", http.StatusOK, []byte(`{"code":200}`))
+	time.Sleep(1100 * time.Millisecond)
+	replay, err := idem.Begin(ctx, "crash-key", fingerprint)
+	if err != nil || replay == nil || !replay.Completed {
+		t.Fatalf("begin after complete = (%v, %v), want stored response", replay, err)
+	}
+	if replay.ResponseBody != `{"code":200}` {
+		t.Fatalf("stored body = %s", replay.ResponseBody)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyedLocker 按 key（user_id）加锁的锁管理器
+// 每个 key 一把互斥锁，不同账户的扣款可以并行；
+// 锁对象带引用计数，最后一个持有者释放后立即回收，避免 map 无限增长。
+// 等待统计跟随锁对象保存，回收时合并进 released 汇总项，统计占用的内存同样只与活跃 key 数有关
+type KeyedLocker struct {
+	mu       sync.Mutex
+	locks    map[int64]*keyedLockEntry
+	released KeyLockStats // 已回收的 key 的累计统计
+}
+
+// keyedLockEntry 单个 key 的锁及其引用计数
+type keyedLockEntry struct {
+	mu    sync.Mutex
+	refs  int          // 持有锁或正在等待锁的协程数
+	stats KeyLockStats // 本次活跃期间的等待统计，受 KeyedLocker.mu 保护
+}
+
+// ReleasedKeysStatsKey 汇总项的 Key，合并了所有已回收（当前没有持有者和等待者）的 key
+const ReleasedKeysStatsKey int64 = -1
+
+// KeyLockStats 单个 key 的锁等待统计
+type KeyLockStats struct {
+	Key          int64   `json:"key"`
+	Acquisitions int64   `json:"acquisitions"`            // 累计加锁次数
+	Waiting      int     `json:"waiting"`                 // 当前持有或等待该锁的协程数
+	TotalWaitMs  float64 `json:"total_wait_ms"`           // 累计等待时间
+	AvgWaitMs    float64 `json:"avg_wait_ms"`             // 平均等待时间
+	MaxWaitMs    float64 `json:"max_wait_ms"`             // 最长一次等待
+	ReleasedKeys int64   `json:"released_keys,omitempty"` // 仅汇总项：合并进来的 key 数（同一 key 多次回收分别计数）
+}
+
+// merge 把 other 的累计值合并进 st
+func (st *KeyLockStats) merge(other *KeyLockStats) {
+	st.Acquisitions += other.Acquisitions
+	st.TotalWaitMs += other.TotalWaitMs
+	if other.MaxWaitMs > st.MaxWaitMs {
+		st.MaxWaitMs = other.MaxWaitMs
+	}
+	if st.Acquisitions > 0 {
+		st.AvgWaitMs = st.TotalWaitMs / float64(st.Acquisitions)
+	}
+}
+
+// NewKeyedLocker 创建锁管理器
+func NewKeyedLocker() *KeyedLocker {
+	return &KeyedLocker{
+		locks:    make(map[int64]*keyedLockEntry),
+		released: KeyLockStats{Key: ReleasedKeysStatsKey},
+	}
+}
+
+// Lock 获取 key 对应的锁，返回释放函数和本次等待时间
+func (l *KeyedLocker) Lock(key int64) (unlock func(), waited time.Duration) {
+	l.mu.Lock()
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &keyedLockEntry{stats: KeyLockStats{Key: key}}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	start := time.Now()
+	entry.mu.Lock()
+	waited = time.Since(start)
+
+	l.recordWait(entry, waited)
+
+	var once sync.Once
+	unlock = func() {
+		once.Do(func() {
+			entry.mu.Unlock()
+
+			l.mu.Lock()
+			entry.refs--
+			if entry.refs == 0 {
+				delete(l.locks, key)
+				l.released.merge(&entry.stats)
+				l.released.ReleasedKeys++
+			}
+			l.mu.Unlock()
+		})
+	}
+	return unlock, waited
+}
+
+// recordWait 记录一次加锁的等待时间
+func (l *KeyedLocker) recordWait(entry *keyedLockEntry, waited time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	waitMs := float64(waited) / float64(time.Millisecond)
+	entry.stats.merge(&KeyLockStats{Acquisitions: 1, TotalWaitMs: waitMs, MaxWaitMs: waitMs})
+}
+
+// Stats 返回活跃 key 的锁等待统计（按 key 排序），已回收的 key 合并为 Key 为 ReleasedKeysStatsKey 的汇总项排在最前
+func (l *KeyedLocker) Stats() []KeyLockStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]KeyLockStats, 0, len(l.locks)+1)
+	if l.released.ReleasedKeys > 0 {
+		result = append(result, l.released)
+	}
+	for _, entry := range l.locks {
+		snapshot := entry.stats
+		snapshot.Waiting = entry.refs
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// ResetStats 清空锁等待统计（不影响正在持有的锁）
+func (l *KeyedLocker) ResetStats() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = KeyLockStats{Key: ReleasedKeysStatsKey}
+	for key, entry := range l.locks {
+		entry.stats = KeyLockStats{Key: key}
+	}
+}
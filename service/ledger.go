@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+// 流水分页参数
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+// TransactionPage 一页账户流水
+type TransactionPage struct {
+	Items      []model.Transaction `json:"items"`
+	NextCursor int64               `json:"next_cursor,omitempty"` // 下一页的游标，为 0 表示没有更多
+	HasMore    bool                `json:"has_more"`
+}
+
+// newDeductTransaction 根据一次成功的扣款（或入账）构造流水
+func newDeductTransaction(req *DeductRequest, requestID, strategy string, oldBalance, newBalance int64, timeline *Timeline) *model.Transaction {
+	return &model.Transaction{
+		RequestID:     requestID,
+		UserID:        req.UserID,
+		Type:          req.transactionType(),
+		Amount:        req.Amount,
+		BalanceBefore: oldBalance,
+		BalanceAfter:  newBalance,
+		Strategy:      strategy,
+		ReadStart:     timeline.ReadStart,
+		ReadEnd:       timeline.ReadEnd,
+		ComputeStart:  timeline.ComputeStart,
+		ComputeEnd:    timeline.ComputeEnd,
+		WriteStart:    timeline.WriteStart,
+		WriteEnd:      timeline.WriteEnd,
+	}
+}
+
+// newResetTransaction 构造重置余额的流水
+func newResetTransaction(userID int64, oldBalance, newBalance int64) *model.Transaction {
+	now := time.Now()
+	return &model.Transaction{
+		RequestID:     fmt.Sprintf("reset-%d", now.UnixNano()),
+		UserID:        userID,
+		Type:          model.TransactionTypeReset,
+		BalanceBefore: oldBalance,
+		BalanceAfter:  newBalance,
+		WriteStart:    now.UnixNano(),
+		WriteEnd:      now.UnixNano(),
+	}
+}
+
+// writeBalanceWithLedger 在一个事务内确认账户未关闭，然后无条件写入新余额并追加流水
+// 供"读取-计算"在事务外完成的策略使用：流水与余额写入同时生效，但不改变策略本身的并发行为，
+// 不加锁模式下被覆盖的扣款依然留有流水，可以据此找出丢失的更新
+func (s *AccountService) writeBalanceWithLedger(ctx context.Context, req *DeductRequest, requestID, strategy string, oldBalance, newBalance int64, timeline *Timeline) error {
+	return s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		timeline.WriteStart = time.Now().UnixNano()
+		if err := ensureActive(ctx, tx, req.UserID); err != nil {
+			return err
+		}
+		err := tx.UpdateBalance(ctx, req.UserID, newBalance)
+		timeline.WriteEnd = time.Now().UnixNano()
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		return s.appendDeductTransaction(ctx, tx, req, requestID, strategy, oldBalance, newBalance, timeline)
+	})
+}
+
+// appendDeductTransaction 在给定的存储（通常是事务）上追加扣款（或入账）流水
+func (s *AccountService) appendDeductTransaction(ctx context.Context, repo repository.AccountRepository, req *DeductRequest, requestID, strategy string, oldBalance, newBalance int64, timeline *Timeline) error {
+	if err := repo.AppendTransaction(ctx, newDeductTransaction(req, requestID, strategy, oldBalance, newBalance, timeline)); err != nil {
+		return fmt.Errorf("failed to append transaction: %w", err)
+	}
+	return nil
+}
+
+// ListTransactions 按 ID 从新到旧分页查询账户流水
+// cursor 为上一页返回的 NextCursor，0 表示第一页；limit 不合法时使用默认值
+func (s *AccountService) ListTransactions(ctx context.Context, userID int64, cursor int64, limit int) (*TransactionPage, error) {
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	if limit > maxTransactionPageSize {
+		limit = maxTransactionPageSize
+	}
+
+	// 多取一条用于判断是否还有下一页
+	txns, err := s.repo.ListTransactions(ctx, userID, cursor, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	page := &TransactionPage{Items: txns}
+	if len(txns) > limit {
+		page.Items = txns[:limit]
+		page.HasMore = true
+		page.NextCursor = page.Items[limit-1].ID
+	}
+	if page.Items == nil {
+		page.Items = []model.Transaction{}
+	}
+	return page, nil
+}
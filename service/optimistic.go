@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+)
+
+// StrategyOptimistic 乐观锁策略名称
+const StrategyOptimistic = "optimistic"
+
+// ErrOptimisticConflict 乐观锁重试次数耗尽仍未写入成功
+var ErrOptimisticConflict = errors.New("optimistic lock conflict: retries exhausted")
+
+// 乐观锁默认配置，config.yaml 未配置时使用
+const (
+	defaultOptimisticMaxAttempts  = 5
+	defaultOptimisticBackoffMs    = 5
+	defaultOptimisticMaxBackoffMs = 100
+)
+
+// optimisticSettings 读取乐观锁配置，未配置的项使用默认值
+func optimisticSettings() config.OptimisticConfig {
+	settings := config.OptimisticConfig{
+		MaxAttempts:  defaultOptimisticMaxAttempts,
+		BackoffMs:    defaultOptimisticBackoffMs,
+		MaxBackoffMs: defaultOptimisticMaxBackoffMs,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.Optimistic.MaxAttempts > 0 {
+		settings.MaxAttempts = cfg.Strategy.Optimistic.MaxAttempts
+	}
+	if cfg.Strategy.Optimistic.BackoffMs > 0 {
+		settings.BackoffMs = cfg.Strategy.Optimistic.BackoffMs
+	}
+	if cfg.Strategy.Optimistic.MaxBackoffMs > 0 {
+		settings.MaxBackoffMs = cfg.Strategy.Optimistic.MaxBackoffMs
+	}
+	return settings
+}
+
+// DeductBalanceOptimistic 扣减余额（乐观锁版本）
+// 读取时记下 version，写入时用 WHERE version=? 做 CAS：
+// 影响行数为 0 说明期间有其他请求写入，退避后重新读取重试
+func (s *AccountService) DeductBalanceOptimistic(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	settings := optimisticSettings()
+	var timeline Timeline
+	lastClass := ""
+
+	for attempt := 1; attempt <= settings.MaxAttempts; attempt++ {
+		record := Attempt{Attempt: attempt}
+
+		// 步骤1: 读取余额和版本号
+		record.ReadStart = time.Now().UnixNano()
+		account, err := s.getAccount(ctx, req.UserID)
+		record.ReadEnd = time.Now().UnixNano()
+		if err != nil {
+			return nil, err
+		}
+		record.ReadBalance = account.Balance
+		record.ReadVersion = account.Version
+		log.Printf("[%s] 🔁 [OPTIMISTIC #%d] 读取余额=%d分 version=%d", requestID, attempt, account.Balance, account.Version)
+
+		// 步骤2: 按账户策略检查余额和限额（入账不检查）
+		if err := checkDeduct(ctx, s.repo, req, account); err != nil {
+			return nil, err
+		}
+
+		// 步骤3: 计算阶段（与其他模式保持相同的业务延迟，方便对比）
+		record.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		newBalance := req.apply(account.Balance)
+		record.ComputeEnd = time.Now().UnixNano()
+
+		// 步骤4: CAS 写入，版本号不匹配时影响行数为 0；写入成功时在同一事务内重新检查累计上限并追加流水
+		var swapped bool
+		record.WriteStart = time.Now().UnixNano()
+		err = s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+			var err error
+			swapped, err = tx.CompareAndSwapBalance(ctx, req.UserID, account.Version, newBalance)
+			record.WriteEnd = time.Now().UnixNano()
+			if err != nil {
+				return fmt.Errorf("failed to update balance: %w", err)
+			}
+			if !swapped {
+				return nil
+			}
+			if !req.Credit {
+				if err := recheckDailyLimit(ctx, tx, account, req.Amount); err != nil {
+					return err
+				}
+			}
+			return s.appendDeductTransaction(ctx, tx, req, requestID, StrategyOptimistic, account.Balance, newBalance, &Timeline{
+				ReadStart:    record.ReadStart,
+				ReadEnd:      record.ReadEnd,
+				ComputeStart: record.ComputeStart,
+				ComputeEnd:   record.ComputeEnd,
+				WriteStart:   record.WriteStart,
+				WriteEnd:     record.WriteEnd,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		record.Success = swapped
+		timeline.Attempts = append(timeline.Attempts, record)
+
+		if record.Success {
+			log.Printf("[%s] 🔁 [OPTIMISTIC #%d] CAS 成功，新余额=%d分", requestID, attempt, newBalance)
+
+			timeline.ReadStart = record.ReadStart
+			timeline.ReadEnd = record.ReadEnd
+			timeline.ComputeStart = record.ComputeStart
+			timeline.ComputeEnd = record.ComputeEnd
+			timeline.WriteStart = record.WriteStart
+			timeline.WriteEnd = record.WriteEnd
+
+			return &DeductResponse{
+				UserID:     req.UserID,
+				Balance:    newBalance,
+				OldBalance: account.Balance,
+				RequestID:  requestID,
+				Retries:    attempt - 1,
+				ErrorClass: lastClass,
+				Timeline:   timeline,
+			}, nil
+		}
+
+		log.Printf("[%s] 🔁 [OPTIMISTIC #%d] CAS 失败，version=%d 已被其他请求修改", requestID, attempt, account.Version)
+		lastClass = ErrorClassVersionConflict
+		if attempt == settings.MaxAttempts {
+			break
+		}
+
+		// 指数退避，避免冲突请求同时重试再次撞车
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(optimisticBackoff(settings, attempt)):
+		}
+	}
+
+	return nil, &RetryError{
+		Retries: settings.MaxAttempts - 1,
+		Class:   ErrorClassVersionConflict,
+		Err:     ErrOptimisticConflict,
+	}
+}
+
+// optimisticBackoff 第 attempt 次失败后的退避时间，从 BackoffMs 开始翻倍，不超过 MaxBackoffMs
+func optimisticBackoff(settings config.OptimisticConfig, attempt int) time.Duration {
+	backoff := time.Duration(settings.BackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(settings.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// optimisticStrategy 乐观锁策略，对应 DeductBalanceOptimistic
+type optimisticStrategy struct {
+	svc *AccountService
+}
+
+func (st *optimisticStrategy) Name() string { return StrategyOptimistic }
+
+func (st *optimisticStrategy) Description() string {
+	return "乐观锁：version 列做 CAS 更新，冲突时按指数退避重试"
+}
+
+func (st *optimisticStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceOptimistic(ctx, req, requestID)
+}
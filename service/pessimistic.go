@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+)
+
+// StrategyPessimistic 悲观锁策略名称
+const StrategyPessimistic = "pessimistic"
+
+// 悲观锁默认配置，config.yaml 未配置时使用
+const (
+	defaultPessimisticIsolation       = "" // 使用数据库默认隔离级别
+	defaultPessimisticLockWaitTimeout = 5
+)
+
+// pessimisticSettings 读取悲观锁配置，未配置的项使用默认值
+func pessimisticSettings() config.PessimisticConfig {
+	settings := config.PessimisticConfig{
+		IsolationLevel:     defaultPessimisticIsolation,
+		LockWaitTimeoutSec: defaultPessimisticLockWaitTimeout,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.Pessimistic.IsolationLevel != "" {
+		settings.IsolationLevel = cfg.Strategy.Pessimistic.IsolationLevel
+	}
+	if cfg.Strategy.Pessimistic.LockWaitTimeoutSec > 0 {
+		settings.LockWaitTimeoutSec = cfg.Strategy.Pessimistic.LockWaitTimeoutSec
+	}
+	return settings
+}
+
+// parseIsolationLevel 将配置中的隔离级别名称转换为 sql.IsolationLevel
+func parseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "", "DEFAULT":
+		return sql.LevelDefault, nil
+	case "READ UNCOMMITTED":
+		return sql.LevelReadUncommitted, nil
+	case "READ COMMITTED":
+		return sql.LevelReadCommitted, nil
+	case "REPEATABLE READ":
+		return sql.LevelRepeatableRead, nil
+	case "SERIALIZABLE":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level: %q", name)
+	}
+}
+
+// DeductBalanceForUpdate 扣减余额（悲观锁版本）
+// 在事务内用 SELECT ... FOR UPDATE 锁住账户行，读取-检查-写入全部在同一事务完成，
+// 其他请求的 FOR UPDATE 会阻塞在行锁上，直到本事务提交
+func (s *AccountService) DeductBalanceForUpdate(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	settings := pessimisticSettings()
+	isolation, err := parseIsolationLevel(settings.IsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeline Timeline
+	var oldBalance, newBalance int64
+
+	txOpts := &repository.TxOptions{
+		Isolation:       isolation,
+		LockWaitTimeout: time.Duration(settings.LockWaitTimeoutSec) * time.Second,
+	}
+	err = s.repo.Transaction(ctx, txOpts, func(tx repository.AccountRepository) error {
+		// 步骤1: 加锁读取，FOR UPDATE 的读取和加锁是同一条语句，这段时间即锁等待时间
+		timeline.LockWaitStart = time.Now().UnixNano()
+		log.Printf("[%s] 🔐 [FOR UPDATE] Step 1: 锁定账户 user_id=%d", requestID, req.UserID)
+		account, err := tx.GetAccountForUpdate(ctx, req.UserID)
+		timeline.LockWaitEnd = time.Now().UnixNano()
+		timeline.ReadStart = timeline.LockWaitStart
+		timeline.ReadEnd = timeline.LockWaitEnd
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+
+		oldBalance = account.Balance
+		log.Printf("[%s] 🔐 [FOR UPDATE] Step 2: 当前余额=%d分，锁等待 %.2fms", requestID, oldBalance,
+			float64(timeline.LockWaitEnd-timeline.LockWaitStart)/float64(time.Millisecond))
+
+		// 步骤2: 按账户策略检查余额和限额（入账不检查）
+		if err := checkDeduct(ctx, tx, req, account); err != nil {
+			return err
+		}
+
+		// 步骤3: 计算阶段（与其他模式保持相同的业务延迟，方便对比）
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		newBalance = req.apply(account.Balance)
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		// 步骤4: 在持有行锁的情况下写入
+		timeline.WriteStart = time.Now().UnixNano()
+		err = tx.UpdateBalance(ctx, req.UserID, newBalance)
+		timeline.WriteEnd = time.Now().UnixNano()
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		if err := s.appendDeductTransaction(ctx, tx, req, requestID, StrategyPessimistic, oldBalance, newBalance, &timeline); err != nil {
+			return err
+		}
+
+		log.Printf("[%s] 🔐 [FOR UPDATE] Step 4: 更新成功，新余额=%d分", requestID, newBalance)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeductResponse{
+		UserID:     req.UserID,
+		Balance:    newBalance,
+		OldBalance: oldBalance,
+		RequestID:  requestID,
+		Timeline:   timeline,
+	}, nil
+}
+
+// pessimisticStrategy 悲观锁策略，对应 DeductBalanceForUpdate
+type pessimisticStrategy struct {
+	svc *AccountService
+}
+
+func (st *pessimisticStrategy) Name() string { return StrategyPessimistic }
+
+func (st *pessimisticStrategy) Description() string {
+	return "悲观锁：事务内 SELECT ... FOR UPDATE 锁住账户行，隔离级别和锁等待超时可配置"
+}
+
+func (st *pessimisticStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceForUpdate(ctx, req, requestID)
+}
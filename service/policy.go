@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+// 策略违规代码，失败响应中的 error_class 与之相同
+const (
+	PolicyCodeSingleAmountExceeded   = "single_amount_exceeded"   // 超过单笔扣款上限
+	PolicyCodeOverdraftLimitExceeded = "overdraft_limit_exceeded" // 扣款后超出透支额度
+	PolicyCodeMinBalanceViolation    = "min_balance_violation"    // 扣款后低于最低余额
+	PolicyCodeDailyLimitExceeded     = "daily_limit_exceeded"     // 最近 24 小时累计扣款超过上限
+)
+
+// dailyLimitWindow 累计扣款上限的滚动窗口
+const dailyLimitWindow = 24 * time.Hour
+
+// dailyLimitTypes 计入累计扣款上限的流水类型：普通扣款、预授权扣款和转账转出都是资金流出，一样计入
+var dailyLimitTypes = []string{model.TransactionTypeDeduct, model.TransactionTypeCapture, model.TransactionTypeTransferOut}
+
+var (
+	// ErrPolicyViolation 扣款违反账户策略，具体原因见 PolicyError
+	ErrPolicyViolation = errors.New("policy violation")
+
+	// ErrInvalidPolicy 账户策略参数不合法
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
+// PolicyError 扣款违反账户策略的详细信息，errors.Is(err, ErrPolicyViolation) 为 true
+// Current 的含义随 Code 变化：透支额度和最低余额为扣款前的可用余额，累计上限为窗口内已扣金额，单笔上限为 0
+type PolicyError struct {
+	Code    string `json:"code"`
+	Limit   int64  `json:"limit"`   // 触发的策略限额（分）
+	Current int64  `json:"current"` // 当前值（分）
+	Amount  int64  `json:"amount"`  // 本次扣款金额（分）
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy violation: %s (limit=%d, current=%d, amount=%d)", e.Code, e.Limit, e.Current, e.Amount)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// checkPolicy 检查单笔上限和扣款后的余额下限，available 为扣款前的可用余额
+// 没有设置透支额度和最低余额时余额下限为 0，不足时仍返回 ErrInsufficientBalance
+func checkPolicy(policy model.AccountPolicy, available, amount int64) error {
+	if err := checkSingleAmount(policy, amount); err != nil {
+		return err
+	}
+	if available-amount >= policy.Floor() {
+		return nil
+	}
+	switch {
+	case policy.OverdraftLimit > 0:
+		return &PolicyError{Code: PolicyCodeOverdraftLimitExceeded, Limit: policy.OverdraftLimit, Current: available, Amount: amount}
+	case policy.MinBalance > 0:
+		return &PolicyError{Code: PolicyCodeMinBalanceViolation, Limit: policy.MinBalance, Current: available, Amount: amount}
+	default:
+		return ErrInsufficientBalance
+	}
+}
+
+// checkSingleAmount 检查单笔上限
+func checkSingleAmount(policy model.AccountPolicy, amount int64) error {
+	if policy.MaxSingleAmount > 0 && amount > policy.MaxSingleAmount {
+		return &PolicyError{Code: PolicyCodeSingleAmountExceeded, Limit: policy.MaxSingleAmount, Amount: amount}
+	}
+	return nil
+}
+
+// dailySpent 汇总最近 24 小时已经扣除的金额，未设置累计上限时不查询
+// repo 须处于读取账户时的同一把锁或同一个事务内，否则汇总结果可能已经过期
+func dailySpent(ctx context.Context, repo repository.AccountRepository, userID int64, policy model.AccountPolicy) (int64, error) {
+	if policy.DailyLimit <= 0 {
+		return 0, nil
+	}
+	spent, err := repo.SumTransactions(ctx, userID, dailyLimitTypes, time.Now().Add(-dailyLimitWindow))
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum transactions: %w", err)
+	}
+	return spent, nil
+}
+
+// checkDailyLimit 检查窗口内已扣金额加上本次是否超过累计上限
+func checkDailyLimit(policy model.AccountPolicy, spent, amount int64) error {
+	if policy.DailyLimit > 0 && spent+amount > policy.DailyLimit {
+		return &PolicyError{Code: PolicyCodeDailyLimitExceeded, Limit: policy.DailyLimit, Current: spent, Amount: amount}
+	}
+	return nil
+}
+
+// recheckDailyLimit 在版本号条件写入成功的事务内重新汇总并检查累计上限
+// 乐观锁在事务外汇总的结果只用于提前拒绝；条件写入之后账户行已被本事务锁定，
+// 其他扣款必须等本事务结束才能写入流水，这里的汇总与写入之间不会再插入扣款
+func recheckDailyLimit(ctx context.Context, tx repository.AccountRepository, account *model.Account, amount int64) error {
+	spent, err := dailySpent(ctx, tx, account.UserID, account.Policy)
+	if err != nil {
+		return err
+	}
+	return checkDailyLimit(account.Policy, spent, amount)
+}
+
+// checkDebit 按账户策略检查从 account 流出 amount：单笔上限、余额下限和累计上限
+func checkDebit(ctx context.Context, repo repository.AccountRepository, account *model.Account, amount int64) error {
+	if err := checkPolicy(account.Policy, account.Available(), amount); err != nil {
+		return err
+	}
+	spent, err := dailySpent(ctx, repo, account.UserID, account.Policy)
+	if err != nil {
+		return err
+	}
+	return checkDailyLimit(account.Policy, spent, amount)
+}
+
+// checkDeduct 按账户策略检查本次扣款，入账只检查金额和账户状态
+// 直接调用策略（不经过 AccountService.Deduct）时同样拒绝非正数金额
+func checkDeduct(ctx context.Context, repo repository.AccountRepository, req *DeductRequest, account *model.Account) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+	if account.Closed() {
+		return ErrAccountClosed
+	}
+	if req.Credit {
+		return nil
+	}
+	return checkDebit(ctx, repo, account, req.Amount)
+}
+
+// validatePolicy 检查策略参数：各项不能为负数，透支额度和最低余额不能同时设置
+func validatePolicy(policy model.AccountPolicy) error {
+	if policy.OverdraftLimit < 0 || policy.MaxSingleAmount < 0 || policy.DailyLimit < 0 || policy.MinBalance < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidPolicy)
+	}
+	if policy.OverdraftLimit > 0 && policy.MinBalance > 0 {
+		return fmt.Errorf("%w: overdraft_limit and min_balance are mutually exclusive", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// UpdateAccountPolicy 修改账户扣款策略，已关闭返回 ErrAccountClosed
+// 新策略只约束之后的扣款，已经低于新下限的余额保持不变
+func (s *AccountService) UpdateAccountPolicy(ctx context.Context, userID int64, policy model.AccountPolicy) (*model.Account, error) {
+	if err := validatePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	var updated *model.Account
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		account, err := tx.GetAccountForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if account.Closed() {
+			return ErrAccountClosed
+		}
+		if err := tx.UpdateAccountPolicy(ctx, userID, policy); err != nil {
+			return err
+		}
+		updated, err = tx.GetAccount(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account policy: %w", err)
+	}
+
+	log.Printf("修改账户策略: user_id=%d, overdraft_limit=%d, max_single_amount=%d, daily_limit=%d, min_balance=%d",
+		userID, policy.OverdraftLimit, policy.MaxSingleAmount, policy.DailyLimit, policy.MinBalance)
+	return updated, nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+)
+
+// deductOnce 用指定策略扣款一次
+func deductOnce(t *testing.T, svc *AccountService, strategy string, userID, amount int64, requestID string) error {
+	t.Helper()
+	st, ok := svc.Strategies().Get(strategy)
+	if !ok {
+		t.Fatalf("strategy %q not registered", strategy)
+	}
+	_, err := svc.Deduct(context.Background(), st, &DeductRequest{UserID: userID, Amount: amount}, requestID)
+	return err
+}
+
+// policyCode 返回 err 中 PolicyError 的违规代码，不是策略违规时返回空
+func policyCode(err error) string {
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr.Code
+	}
+	return ""
+}
+
+func TestDeductPolicyViolations(t *testing.T) {
+	const (
+		userID  int64 = 1
+		initial int64 = 1000
+	)
+
+	tests := []struct {
+		name    string
+		policy  model.AccountPolicy
+		allowed []int64 // 先执行、应当成功的扣款
+		amount  int64   // 应当被拒绝的扣款
+		code    string
+	}{
+		{
+			name:    "overdraft",
+			policy:  model.AccountPolicy{OverdraftLimit: 500},
+			allowed: []int64{1200},
+			amount:  400,
+			code:    PolicyCodeOverdraftLimitExceeded,
+		},
+		{
+			name:   "single max",
+			policy: model.AccountPolicy{MaxSingleAmount: 300},
+			amount: 301,
+			code:   PolicyCodeSingleAmountExceeded,
+		},
+		{
+			name:    "daily limit",
+			policy:  model.AccountPolicy{DailyLimit: 500},
+			allowed: []int64{300, 200},
+			amount:  1,
+			code:    PolicyCodeDailyLimitExceeded,
+		},
+		{
+			name:    "min balance",
+			policy:  model.AccountPolicy{MinBalance: 800},
+			allowed: []int64{200},
+			amount:  1,
+			code:    PolicyCodeMinBalanceViolation,
+		},
+	}
+
+	svc, _ := newTestService(t)
+	for _, info := range svc.Strategies().List() {
+		for _, tt := range tests {
+			t.Run(info.Name+"/"+tt.name, func(t *testing.T) {
+				svc, repo := newTestService(t)
+				createTestAccount(t, svc, userID, initial)
+				if _, err := svc.UpdateAccountPolicy(context.Background(), userID, tt.policy); err != nil {
+					t.Fatalf("update policy: %v", err)
+				}
+
+				want := initial
+				for i, amount := range tt.allowed {
+					if err := deductOnce(t, svc, info.Name, userID, amount, fmt.Sprintf("allowed-%d", i)); err != nil {
+						t.Fatalf("deduct %d: %v", amount, err)
+					}
+					want -= amount
+				}
+
+				err := deductOnce(t, svc, info.Name, userID, tt.amount, "rejected")
+				if !errors.Is(err, ErrPolicyViolation) || policyCode(err) != tt.code {
+					t.Fatalf("deduct %d err = %v, want policy violation %q", tt.amount, err, tt.code)
+				}
+				if balance := balanceOf(t, svc, userID); balance != want {
+					t.Errorf("balance = %d, want %d (rejected deduction must not write)", balance, want)
+				}
+				if ledger := countLedger(t, repo, userID, model.TransactionTypeDeduct); ledger != len(tt.allowed) {
+					t.Errorf("ledger rows = %d, want %d", ledger, len(tt.allowed))
+				}
+			})
+		}
+	}
+}
+
+func TestDeductDailyLimitConcurrent(t *testing.T) {
+	useTestConfig(t, &config.Config{Strategy: config.StrategyConfig{
+		Optimistic: config.OptimisticConfig{MaxAttempts: 100, BackoffMs: 1, MaxBackoffMs: 20},
+	}})
+
+	const (
+		userID     int64 = 1
+		initial    int64 = 100000
+		amount     int64 = 100
+		dailyLimit int64 = 1000
+		n                = 20 // 请求总额是累计上限的两倍
+	)
+
+	svc, _ := newTestService(t)
+	for _, info := range svc.Strategies().List() {
+		// unlocked 的检查和写入之间没有保护，并发时本来就会超限
+		if info.Name == StrategyUnlocked {
+			continue
+		}
+		t.Run(info.Name, func(t *testing.T) {
+			svc, repo := newTestService(t)
+			createTestAccount(t, svc, userID, initial)
+			if _, err := svc.UpdateAccountPolicy(context.Background(), userID, model.AccountPolicy{DailyLimit: dailyLimit}); err != nil {
+				t.Fatalf("update policy: %v", err)
+			}
+
+			accepted, errs := deductConcurrently(t, svc, info.Name, userID, n, amount)
+			for _, err := range errs {
+				if policyCode(err) != PolicyCodeDailyLimitExceeded {
+					t.Fatalf("deduct err = %v, want policy violation %q", err, PolicyCodeDailyLimitExceeded)
+				}
+			}
+
+			if want := int(dailyLimit / amount); accepted != want {
+				t.Errorf("accepted = %d, want %d", accepted, want)
+			}
+			if spent := initial - balanceOf(t, svc, userID); spent > dailyLimit {
+				t.Errorf("spent = %d exceeds daily limit %d", spent, dailyLimit)
+			}
+			if ledger := countLedger(t, repo, userID, model.TransactionTypeDeduct); ledger != accepted {
+				t.Errorf("ledger rows = %d, want %d", ledger, accepted)
+			}
+		})
+	}
+}
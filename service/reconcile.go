@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+
+	"github.com/google/uuid"
+)
+
+// 对账默认配置，config.yaml 未配置时使用
+const (
+	defaultReconcileIntervalSec = 30
+	reconcileLedgerPageSize     = 500 // 向前查找重置流水时每次读取的条数
+	maxImplicatedRequestIDs     = 100 // 单份报告最多记录的涉及请求数
+)
+
+// reconcileSettings 读取对账配置，未配置的项使用默认值
+func reconcileSettings() config.ReconcileConfig {
+	settings := config.ReconcileConfig{
+		IntervalSec: defaultReconcileIntervalSec,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	settings.Enabled = cfg.Reconcile.Enabled
+	if cfg.Reconcile.IntervalSec > 0 {
+		settings.IntervalSec = cfg.Reconcile.IntervalSec
+	}
+	return settings
+}
+
+// ReconcileRun 一次对账的结果
+type ReconcileRun struct {
+	RunID           string                       `json:"run_id"`
+	StartedAt       time.Time                    `json:"started_at"`
+	FinishedAt      time.Time                    `json:"finished_at"`
+	AccountsChecked int                          `json:"accounts_checked"`
+	Discrepancies   []model.ReconciliationReport `json:"discrepancies"` // 只包含余额不一致的账户
+}
+
+// ReconciliationReportPage 一页对账报告
+type ReconciliationReportPage struct {
+	Items      []model.ReconciliationReport `json:"items"`
+	NextCursor int64                        `json:"next_cursor,omitempty"` // 下一页的游标，为 0 表示没有更多
+	HasMore    bool                         `json:"has_more"`
+}
+
+// Reconciler 对账任务
+// 对每个账户从最近一次重置开始重放流水，重放结果与 accounts.balance 不一致时生成差异报告并持久化。
+// 可以后台定时执行，也可以通过 Run 按需执行；同一时间只有一次对账在运行
+type Reconciler struct {
+	svc     *AccountService
+	reports repository.ReconciliationRepository
+
+	runMu sync.Mutex // 保证同一时间只有一次对账
+
+	observerMu sync.RWMutex
+	observer   func(*ReconcileRun)
+
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewReconciler 创建对账任务
+func NewReconciler(svc *AccountService, reports repository.ReconciliationRepository) *Reconciler {
+	return &Reconciler{
+		svc:     svc,
+		reports: reports,
+	}
+}
+
+// OnRun 注册对账完成后的回调，用于向前端推送结果
+func (r *Reconciler) OnRun(fn func(*ReconcileRun)) {
+	r.observerMu.Lock()
+	defer r.observerMu.Unlock()
+	r.observer = fn
+}
+
+// Start 按 reconcile 配置启动后台定时对账，未启用时不做任何事
+func (r *Reconciler) Start() {
+	settings := reconcileSettings()
+	if !settings.Enabled {
+		log.Println("后台对账未启用（reconcile.enabled=false）")
+		return
+	}
+
+	r.stopChan = make(chan struct{})
+	r.done = make(chan struct{})
+	interval := time.Duration(settings.IntervalSec) * time.Second
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("后台对账任务已启动（%v 间隔）", interval)
+		for {
+			select {
+			case <-r.stopChan:
+				log.Println("后台对账任务已停止")
+				return
+			case <-ticker.C:
+				if _, err := r.Run(context.Background()); err != nil {
+					log.Printf("后台对账失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止后台对账并等待当前对账完成
+func (r *Reconciler) Stop() {
+	if r.stopChan == nil {
+		return
+	}
+	close(r.stopChan)
+	<-r.done
+}
+
+// Run 对所有账户执行一次对账，持久化差异报告并通知回调
+func (r *Reconciler) Run(ctx context.Context) (*ReconcileRun, error) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	run := &ReconcileRun{
+		RunID:         uuid.New().String()[:8],
+		StartedAt:     time.Now(),
+		Discrepancies: []model.ReconciliationReport{},
+	}
+
+	accounts, err := r.svc.repo.ListAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	for _, account := range accounts {
+		report, err := r.svc.reconcileAccount(ctx, account.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconcile account %d: %w", account.UserID, err)
+		}
+		run.AccountsChecked++
+		if report.LostAmount != 0 {
+			report.RunID = run.RunID
+			run.Discrepancies = append(run.Discrepancies, *report)
+		}
+	}
+
+	if err := r.reports.SaveReconciliationReports(ctx, run.Discrepancies); err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation reports: %w", err)
+	}
+	run.FinishedAt = time.Now()
+
+	log.Printf("对账完成: run_id=%s, 账户数=%d, 差异数=%d", run.RunID, run.AccountsChecked, len(run.Discrepancies))
+
+	r.observerMu.RLock()
+	fn := r.observer
+	r.observerMu.RUnlock()
+	if fn != nil {
+		fn(run)
+	}
+	return run, nil
+}
+
+// Reports 按 ID 从新到旧分页查询已持久化的对账报告
+func (r *Reconciler) Reports(ctx context.Context, cursor int64, limit int) (*ReconciliationReportPage, error) {
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+	if limit > maxTransactionPageSize {
+		limit = maxTransactionPageSize
+	}
+
+	reports, err := r.reports.ListReconciliationReports(ctx, cursor, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation reports: %w", err)
+	}
+
+	page := &ReconciliationReportPage{Items: reports}
+	if len(reports) > limit {
+		page.Items = reports[:limit]
+		page.HasMore = true
+		page.NextCursor = page.Items[limit-1].ID
+	}
+	if page.Items == nil {
+		page.Items = []model.ReconciliationReport{}
+	}
+	return page, nil
+}
+
+// reconcileAccount 重放单个账户的流水并与实际余额比较
+// 在事务内先锁住账户行再读取流水：流水与余额写入在同一事务提交，锁住行后读到的两者是一致的
+func (s *AccountService) reconcileAccount(ctx context.Context, userID int64) (*model.ReconciliationReport, error) {
+	var report *model.ReconciliationReport
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		account, err := tx.GetAccountForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		entries, err := ledgerSinceLastReset(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		report = replayLedger(entries, account.Balance)
+		report.UserID = userID
+		return nil
+	})
+	return report, err
+}
+
+// ledgerSinceLastReset 读取账户最近一次重置（含）之后的全部流水，按 ID 升序
+// 没有重置流水时返回全部流水
+func ledgerSinceLastReset(ctx context.Context, repo repository.AccountRepository, userID int64) ([]model.Transaction, error) {
+	var entries []model.Transaction
+	var cursor int64
+	for {
+		page, err := repo.ListTransactions(ctx, userID, cursor, reconcileLedgerPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, txn := range page {
+			entries = append(entries, txn)
+			if txn.Type == model.TransactionTypeReset {
+				found = true
+				break
+			}
+		}
+		if found || len(page) < reconcileLedgerPageSize {
+			break
+		}
+		cursor = page[len(page)-1].ID
+	}
+
+	// 从新到旧读取，翻转为按时间顺序重放
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// replayLedger 按顺序重放流水得到理论余额，并找出涉及丢失更新的请求
+// 起点是重置流水的 balance_after；没有重置流水时取第一条流水的 balance_before。
+// 流水按提交顺序排列，正常情况下每条流水的 balance_before 都等于上一条的 balance_after；
+// 不相等说明这条流水读到了过期余额，它覆盖了上一条的写入，两者都记为涉及的请求
+func replayLedger(entries []model.Transaction, actual int64) *model.ReconciliationReport {
+	report := &model.ReconciliationReport{
+		ExpectedBalance:      actual,
+		ActualBalance:        actual,
+		TransactionCount:     len(entries),
+		ImplicatedRequestIDs: model.RequestIDList{},
+	}
+	if len(entries) == 0 {
+		return report
+	}
+
+	first := entries[0]
+	report.BaselineTransactionID = first.ID
+	expected := first.BalanceBefore
+
+	implicated := make(map[string]bool)
+	implicate := func(requestID string) {
+		if !implicated[requestID] && len(report.ImplicatedRequestIDs) < maxImplicatedRequestIDs {
+			implicated[requestID] = true
+			report.ImplicatedRequestIDs = append(report.ImplicatedRequestIDs, requestID)
+		}
+	}
+
+	for i, txn := range entries {
+		if i > 0 && txn.BalanceBefore != entries[i-1].BalanceAfter {
+			implicate(entries[i-1].RequestID)
+			implicate(txn.RequestID)
+		}
+
+		switch txn.Type {
+		case model.TransactionTypeReset:
+			expected = txn.BalanceAfter
+		case model.TransactionTypeDeduct, model.TransactionTypeTransferOut, model.TransactionTypeCapture:
+			expected -= txn.Amount
+		case model.TransactionTypeCredit, model.TransactionTypeTransferIn:
+			expected += txn.Amount
+		}
+	}
+
+	report.ExpectedBalance = expected
+	report.LostAmount = actual - expected
+	return report
+}
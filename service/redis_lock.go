@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockLost 持有期间锁租约丢失（续期失败或已被他人持有）
+var ErrLockLost = errors.New("lock lease lost")
+
+// releaseScript 只有 token 匹配时才删除 key，避免误删别人的锁
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewScript 只有 token 匹配时才续期
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// RedisLockOptions Redis 锁参数
+type RedisLockOptions struct {
+	TTL            time.Duration // 租约时长
+	AcquireTimeout time.Duration // 获取锁最长等待时间
+	RetryInterval  time.Duration // 获取失败后的重试间隔
+}
+
+// RedisLocker 基于 Redis 的分布式锁服务
+// 加锁：SET key token NX PX ttl；释放：Lua 校验 token 后 DEL；
+// 持有期间由看门狗协程每 ttl/3 续期一次，临界区耗时超过 ttl 也不会丢锁。
+// client 可以是任何 go-redis 客户端（包括指向进程内 Redis 替身的客户端），便于测试多实例互斥
+type RedisLocker struct {
+	client redis.UniversalClient
+	opts   RedisLockOptions
+}
+
+// RedisLock 已获取的锁
+type RedisLock struct {
+	locker *RedisLocker
+	key    string
+	token  string
+
+	lost     atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRedisLocker 创建 Redis 锁服务
+func NewRedisLocker(client redis.UniversalClient, opts RedisLockOptions) *RedisLocker {
+	return &RedisLocker{client: client, opts: opts}
+}
+
+// Acquire 获取锁，在 AcquireTimeout 内轮询重试，超时返回 ErrLockTimeout
+func (l *RedisLocker) Acquire(ctx context.Context, key string) (*RedisLock, error) {
+	token := uuid.New().String()
+	deadline := time.Now().Add(l.opts.AcquireTimeout)
+
+	for {
+		ok, err := l.client.SetNX(ctx, key, token, l.opts.TTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire redis lock: %w", err)
+		}
+		if ok {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(l.opts.RetryInterval):
+		}
+	}
+
+	lock := &RedisLock{
+		locker: l,
+		key:    key,
+		token:  token,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go lock.watchdog()
+	return lock, nil
+}
+
+// watchdog 定期续期租约，直到锁被释放；续期失败超过一个 TTL 视为锁丢失
+func (lk *RedisLock) watchdog() {
+	defer close(lk.done)
+
+	ttl := lk.locker.opts.TTL
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), ttl/3)
+			res, err := renewScript.Run(ctx, lk.locker.client, []string{lk.key}, lk.token, ttl.Milliseconds()).Int()
+			cancel()
+
+			if err == nil && res == 1 {
+				lastRenewed = time.Now()
+				continue
+			}
+			if err == nil {
+				// token 不匹配：key 已过期并被其他持有者获取
+				log.Printf("Redis 锁 %s 已被其他持有者获取，停止续期", lk.key)
+				lk.lost.Store(true)
+				return
+			}
+			log.Printf("Redis 锁 %s 续期失败: %v", lk.key, err)
+			if time.Since(lastRenewed) >= ttl {
+				lk.lost.Store(true)
+				return
+			}
+		}
+	}
+}
+
+// Lost 租约是否已经丢失，临界区在写入前应检查
+func (lk *RedisLock) Lost() bool {
+	return lk.lost.Load()
+}
+
+// Release 停止看门狗并释放锁，token 不匹配时返回 ErrLockLost
+func (lk *RedisLock) Release(ctx context.Context) error {
+	lk.stopOnce.Do(func() { close(lk.stop) })
+	<-lk.done
+
+	res, err := releaseScript.Run(ctx, lk.locker.client, []string{lk.key}, lk.token).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release redis lock: %w", err)
+	}
+	if res == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
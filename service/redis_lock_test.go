@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestLockers 创建两个共享同一个 miniredis 的 RedisLocker，模拟两个服务实例
+func newTestLockers(t *testing.T, opts RedisLockOptions) (*miniredis.Miniredis, *RedisLocker, *RedisLocker) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+
+	newLocker := func() *RedisLocker {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisLocker(client, opts)
+	}
+	return mr, newLocker(), newLocker()
+}
+
+func TestRedisLockMutualExclusion(t *testing.T) {
+	_, a, b := newTestLockers(t, RedisLockOptions{
+		TTL:            time.Second,
+		AcquireTimeout: 5 * time.Second,
+		RetryInterval:  time.Millisecond,
+	})
+	ctx := context.Background()
+	const key = "zbl:test:lock:1"
+
+	var inside, maxInside, counter int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		locker := a
+		if i%2 == 1 {
+			locker = b
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := locker.Acquire(ctx, key)
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			n := atomic.AddInt32(&inside, 1)
+			for {
+				m := atomic.LoadInt32(&maxInside)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInside, m, n) {
+					break
+				}
+			}
+			// 非原子的读-改-写，没有互斥时会丢失更新
+			v := atomic.LoadInt32(&counter)
+			time.Sleep(2 * time.Millisecond)
+			atomic.StoreInt32(&counter, v+1)
+			atomic.AddInt32(&inside, -1)
+
+			if err := lock.Release(ctx); err != nil {
+				t.Errorf("release: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Fatalf("max holders = %d, want 1", maxInside)
+	}
+	if counter != 20 {
+		t.Fatalf("counter = %d, want 20", counter)
+	}
+}
+
+func TestRedisLockAcquireTimeout(t *testing.T) {
+	_, a, b := newTestLockers(t, RedisLockOptions{
+		TTL:            time.Second,
+		AcquireTimeout: 50 * time.Millisecond,
+		RetryInterval:  5 * time.Millisecond,
+	})
+	ctx := context.Background()
+	const key = "zbl:test:lock:2"
+
+	lock, err := a.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := b.Acquire(ctx, key); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("second acquire err = %v, want ErrLockTimeout", err)
+	}
+
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	lock, err = b.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	lock.Release(ctx)
+}
+
+func TestRedisLockExpiryAndForeignRelease(t *testing.T) {
+	ttl := 300 * time.Millisecond
+	mr, a, b := newTestLockers(t, RedisLockOptions{
+		TTL:            ttl,
+		AcquireTimeout: 50 * time.Millisecond,
+		RetryInterval:  5 * time.Millisecond,
+	})
+	ctx := context.Background()
+	const key = "zbl:test:lock:3"
+
+	stale, err := a.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// 租约在看门狗续期前过期，另一个实例拿到锁
+	mr.FastForward(ttl)
+	owner, err := b.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	defer owner.Release(ctx)
+
+	// 看门狗下一次续期发现 token 不匹配，标记锁丢失
+	deadline := time.Now().Add(2 * ttl)
+	for !stale.Lost() {
+		if time.Now().After(deadline) {
+			t.Fatal("expired lock not marked lost")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if owner.Lost() {
+		t.Fatal("current owner marked lost")
+	}
+
+	// 原持有者释放不影响新持有者
+	if err := stale.Release(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("stale release err = %v, want ErrLockLost", err)
+	}
+	if got, _ := mr.Get(key); got != owner.token {
+		t.Fatalf("lock value = %q, want owner token %q", got, owner.token)
+	}
+}
+
+func TestRedisLockWatchdogRenewsPastTTL(t *testing.T) {
+	ttl := 150 * time.Millisecond
+	mr, a, b := newTestLockers(t, RedisLockOptions{
+		TTL:            ttl,
+		AcquireTimeout: 20 * time.Millisecond,
+		RetryInterval:  5 * time.Millisecond,
+	})
+	ctx := context.Background()
+	const key = "zbl:test:lock:4"
+
+	lock, err := a.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// miniredis 只在 FastForward 时推进过期时间：每次推进 2/3 个 TTL，其间看门狗（每 ttl/3）至少续期一次，
+	// 累计推进 4 个 TTL 后锁仍然存在
+	for i := 0; i < 6; i++ {
+		time.Sleep(ttl * 2 / 3)
+		mr.FastForward(ttl * 2 / 3)
+		if !mr.Exists(key) {
+			t.Fatalf("lease expired after %v of simulated time", time.Duration(i+1)*ttl*2/3)
+		}
+	}
+	if lock.Lost() {
+		t.Fatal("lock marked lost while the watchdog was renewing it")
+	}
+	if _, err := b.Acquire(ctx, key); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("second acquire err = %v, want ErrLockTimeout", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+}
+
+func TestRedisStrategyAcrossInstances(t *testing.T) {
+	const (
+		userID  int64 = 1
+		initial int64 = 100000
+		amount  int64 = 100
+		n             = 20
+	)
+	_, lockerA, lockerB := newTestLockers(t, RedisLockOptions{
+		TTL:            time.Second,
+		AcquireTimeout: 5 * time.Second,
+		RetryInterval:  time.Millisecond,
+	})
+
+	// 两个服务实例共享同一个存储和同一个 Redis，各自持有独立的 Redis 客户端
+	run := func(strategy string) (int64, int) {
+		repo := repository.NewMemoryAccountRepository(repository.MemoryOptions{})
+		instances := []*AccountService{NewAccountService(repo), NewAccountService(repo)}
+		instances[0].UseRedisLocker(lockerA)
+		instances[1].UseRedisLocker(lockerB)
+		createTestAccount(t, instances[0], userID, initial)
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			svc := instances[i%2]
+			st, _ := svc.Strategies().Get(strategy)
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := &DeductRequest{UserID: userID, Amount: amount}
+				if _, err := svc.Deduct(context.Background(), st, req, fmt.Sprintf("%s-%d", strategy, i)); err != nil {
+					t.Errorf("%s deduct: %v", strategy, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		return balanceOf(t, instances[1], userID), countLedger(t, repo, userID, model.TransactionTypeDeduct)
+	}
+
+	balance, ledger := run(StrategyRedis)
+	if want := initial - n*amount; balance != want {
+		t.Errorf("redis: balance = %d, want %d", balance, want)
+	}
+	if ledger != n {
+		t.Errorf("redis: ledger rows = %d, want %d", ledger, n)
+	}
+
+	// 对照：进程内互斥锁跨实例不互斥，同样的负载会丢失更新
+	if balance, _ := run(StrategyMutex); balance == initial-n*amount {
+		t.Errorf("mutex: balance = %d, expected lost updates across instances", balance)
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/config"
+)
+
+// StrategyRedis Redis 分布式锁策略名称
+const StrategyRedis = "redis"
+
+// ErrRedisUnavailable 未初始化 Redis 客户端
+var ErrRedisUnavailable = errors.New("redis client not initialized")
+
+// Redis 锁默认配置，config.yaml 未配置时使用
+const (
+	defaultRedisLockKeyPrefix        = "zbl:lock:account:"
+	defaultRedisLockTTLMs            = 3000
+	defaultRedisLockAcquireTimeoutMs = 5000
+	defaultRedisLockRetryIntervalMs  = 5
+)
+
+// redisLockSettings 读取 Redis 锁配置，未配置的项使用默认值
+func redisLockSettings() config.RedisLockConfig {
+	settings := config.RedisLockConfig{
+		KeyPrefix:        defaultRedisLockKeyPrefix,
+		TTLMs:            defaultRedisLockTTLMs,
+		AcquireTimeoutMs: defaultRedisLockAcquireTimeoutMs,
+		RetryIntervalMs:  defaultRedisLockRetryIntervalMs,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.RedisLock.KeyPrefix != "" {
+		settings.KeyPrefix = cfg.Strategy.RedisLock.KeyPrefix
+	}
+	if cfg.Strategy.RedisLock.TTLMs > 0 {
+		settings.TTLMs = cfg.Strategy.RedisLock.TTLMs
+	}
+	if cfg.Strategy.RedisLock.AcquireTimeoutMs > 0 {
+		settings.AcquireTimeoutMs = cfg.Strategy.RedisLock.AcquireTimeoutMs
+	}
+	if cfg.Strategy.RedisLock.RetryIntervalMs > 0 {
+		settings.RetryIntervalMs = cfg.Strategy.RedisLock.RetryIntervalMs
+	}
+	return settings
+}
+
+// UseRedisLocker 指定 Redis 锁服务（例如指向进程内 Redis 替身的客户端）
+// 未指定时在首次使用时根据 config.GetRedis() 和配置文件创建
+func (s *AccountService) UseRedisLocker(locker *RedisLocker) {
+	s.redisMu.Lock()
+	defer s.redisMu.Unlock()
+	s.redisLocker = locker
+}
+
+// getRedisLocker 获取 Redis 锁服务，必要时按配置创建
+func (s *AccountService) getRedisLocker() (*RedisLocker, error) {
+	s.redisMu.Lock()
+	defer s.redisMu.Unlock()
+
+	if s.redisLocker != nil {
+		return s.redisLocker, nil
+	}
+
+	client := config.GetRedis()
+	if client == nil {
+		return nil, ErrRedisUnavailable
+	}
+
+	settings := redisLockSettings()
+	s.redisLocker = NewRedisLocker(client, RedisLockOptions{
+		TTL:            time.Duration(settings.TTLMs) * time.Millisecond,
+		AcquireTimeout: time.Duration(settings.AcquireTimeoutMs) * time.Millisecond,
+		RetryInterval:  time.Duration(settings.RetryIntervalMs) * time.Millisecond,
+	})
+	return s.redisLocker, nil
+}
+
+// DeductBalanceWithRedisLock 扣减余额（Redis 分布式锁版本）
+// 锁粒度为单个账户，多个服务实例共享同一个 Redis 时同样互斥
+func (s *AccountService) DeductBalanceWithRedisLock(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	locker, err := s.getRedisLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s%d", redisLockSettings().KeyPrefix, req.UserID)
+
+	lockWaitStart := time.Now().UnixNano()
+	lock, err := locker.Acquire(ctx, key)
+	lockWaitEnd := time.Now().UnixNano()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			log.Printf("[%s] 🌐 [REDIS] 释放锁 %s 失败: %v", requestID, key, err)
+		}
+	}()
+
+	// 写入前确认租约仍然有效，租约丢失说明可能已有其他实例进入临界区
+	resp, err := s.deductInCriticalSection(ctx, req, requestID, StrategyRedis, "🌐 [REDIS]", func() error {
+		if lock.Lost() {
+			return ErrLockLost
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Timeline.LockWaitStart = lockWaitStart
+	resp.Timeline.LockWaitEnd = lockWaitEnd
+	return resp, nil
+}
+
+// redisStrategy Redis 分布式锁策略，对应 DeductBalanceWithRedisLock
+type redisStrategy struct {
+	svc *AccountService
+}
+
+func (st *redisStrategy) Name() string { return StrategyRedis }
+
+func (st *redisStrategy) Description() string {
+	return "Redis 分布式锁：SET NX PX 加锁，Lua 校验 token 释放，看门狗自动续期，跨实例互斥"
+}
+
+func (st *redisStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceWithRedisLock(ctx, req, requestID)
+}
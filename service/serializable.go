@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/repository"
+)
+
+// StrategySerializable 串行化隔离级别策略名称
+const StrategySerializable = "serializable"
+
+// 串行化策略默认配置，config.yaml 未配置时使用
+const (
+	defaultSerializableMaxAttempts        = 5
+	defaultSerializableBackoffMs          = 5
+	defaultSerializableMaxBackoffMs       = 100
+	defaultSerializableLockWaitTimeoutSec = 2
+)
+
+// serializableSettings 读取串行化策略配置，未配置的项使用默认值
+func serializableSettings() config.SerializableConfig {
+	settings := config.SerializableConfig{
+		MaxAttempts:        defaultSerializableMaxAttempts,
+		BackoffMs:          defaultSerializableBackoffMs,
+		MaxBackoffMs:       defaultSerializableMaxBackoffMs,
+		LockWaitTimeoutSec: defaultSerializableLockWaitTimeoutSec,
+	}
+
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return settings
+	}
+	if cfg.Strategy.Serializable.MaxAttempts > 0 {
+		settings.MaxAttempts = cfg.Strategy.Serializable.MaxAttempts
+	}
+	if cfg.Strategy.Serializable.BackoffMs > 0 {
+		settings.BackoffMs = cfg.Strategy.Serializable.BackoffMs
+	}
+	if cfg.Strategy.Serializable.MaxBackoffMs > 0 {
+		settings.MaxBackoffMs = cfg.Strategy.Serializable.MaxBackoffMs
+	}
+	if cfg.Strategy.Serializable.LockWaitTimeoutSec > 0 {
+		settings.LockWaitTimeoutSec = cfg.Strategy.Serializable.LockWaitTimeoutSec
+	}
+	return settings
+}
+
+// jitteredBackoff 计算第 attempt 次失败后的退避时间：指数增长封顶后取 [d/2, d) 的随机值，
+// 避免同时死锁的两个事务又在同一时刻重试
+func jitteredBackoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// DeductBalanceSerializable 扣减余额（SERIALIZABLE 隔离级别版本）
+// 沿用最朴素的"读取-计算-写入"，只把事务隔离级别提升到 SERIALIZABLE：
+// InnoDB 会把普通 SELECT 变成共享锁读，两个并发事务都想升级为排他锁时产生死锁，
+// 数据库回滚其中一个，本方法对死锁（1213）和锁等待超时（1205）带抖动退避后自动重试。
+// PostgreSQL 的 SSI 不加共享锁，冲突以 40001（serialization failure）报告，同样自动重试
+func (s *AccountService) DeductBalanceSerializable(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	settings := serializableSettings()
+	var attempts []Attempt
+	lastClass := ""
+
+	for attempt := 1; ; attempt++ {
+		record := Attempt{Attempt: attempt}
+		resp, err := s.deductSerializableOnce(ctx, req, requestID, settings, &record)
+		record.Success = err == nil
+		attempts = append(attempts, record)
+
+		if err == nil {
+			resp.Retries = attempt - 1
+			resp.ErrorClass = lastClass
+			resp.Timeline.Attempts = attempts
+			return resp, nil
+		}
+
+		class := ClassifyError(err)
+		if !isRetryableClass(class) || attempt >= settings.MaxAttempts {
+			if attempt == 1 && !isRetryableClass(class) {
+				return nil, err
+			}
+			return nil, &RetryError{Retries: attempt - 1, Class: class, Err: err}
+		}
+
+		lastClass = class
+		backoff := jitteredBackoff(attempt,
+			time.Duration(settings.BackoffMs)*time.Millisecond,
+			time.Duration(settings.MaxBackoffMs)*time.Millisecond)
+		log.Printf("[%s] 🧱 [SERIALIZABLE #%d] %s，%v 后重试", requestID, attempt, class, backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// deductSerializableOnce 在 SERIALIZABLE 事务中执行一次"读取-计算-写入"
+func (s *AccountService) deductSerializableOnce(ctx context.Context, req *DeductRequest, requestID string, settings config.SerializableConfig, record *Attempt) (*DeductResponse, error) {
+	var timeline Timeline
+	var oldBalance, newBalance int64
+
+	txOpts := &repository.TxOptions{
+		Isolation:       sql.LevelSerializable,
+		LockWaitTimeout: time.Duration(settings.LockWaitTimeoutSec) * time.Second,
+	}
+	err := s.repo.Transaction(ctx, txOpts, func(tx repository.AccountRepository) error {
+		// 步骤1: 普通读取（SERIALIZABLE 下隐式加共享锁）
+		timeline.ReadStart = time.Now().UnixNano()
+		account, err := tx.GetAccount(ctx, req.UserID)
+		timeline.ReadEnd = time.Now().UnixNano()
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		oldBalance = account.Balance
+		record.ReadBalance = account.Balance
+		record.ReadVersion = account.Version
+
+		// 步骤2: 按账户策略检查余额和限额（入账不检查）
+		if err := checkDeduct(ctx, tx, req, account); err != nil {
+			return err
+		}
+
+		// 步骤3: 计算阶段（与其他模式保持相同的业务延迟，方便对比）
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		newBalance = req.apply(account.Balance)
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		// 步骤4: 写入，需要把共享锁升级为排他锁，并发时在这里发生死锁
+		timeline.WriteStart = time.Now().UnixNano()
+		err = tx.UpdateBalance(ctx, req.UserID, newBalance)
+		timeline.WriteEnd = time.Now().UnixNano()
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		return s.appendDeductTransaction(ctx, tx, req, requestID, StrategySerializable, oldBalance, newBalance, &timeline)
+	})
+
+	record.ReadStart, record.ReadEnd = timeline.ReadStart, timeline.ReadEnd
+	record.ComputeStart, record.ComputeEnd = timeline.ComputeStart, timeline.ComputeEnd
+	record.WriteStart, record.WriteEnd = timeline.WriteStart, timeline.WriteEnd
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] 🧱 [SERIALIZABLE #%d] 提交成功，新余额=%d分", requestID, record.Attempt, newBalance)
+	return &DeductResponse{
+		UserID:     req.UserID,
+		Balance:    newBalance,
+		OldBalance: oldBalance,
+		RequestID:  requestID,
+		Timeline:   timeline,
+	}, nil
+}
+
+// serializableStrategy 串行化隔离级别策略，对应 DeductBalanceSerializable
+type serializableStrategy struct {
+	svc *AccountService
+}
+
+func (st *serializableStrategy) Name() string { return StrategySerializable }
+
+func (st *serializableStrategy) Description() string {
+	return "SERIALIZABLE 隔离级别：不显式加锁，依赖数据库检测冲突，死锁/锁等待超时/串行化失败自动抖动退避重试"
+}
+
+func (st *serializableStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceSerializable(ctx, req, requestID)
+}
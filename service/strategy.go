@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// 内置策略名称
+const (
+	StrategyUnlocked = "unlocked" // 不加锁，演示 Lost Update
+	StrategyMutex    = "mutex"    // 进程内互斥锁
+
+	// DefaultStrategy 服务启动时默认使用的策略
+	DefaultStrategy = StrategyUnlocked
+)
+
+// DeductStrategy 扣款并发控制策略
+// 每种策略封装一种"读取-计算-写入"的并发控制手段，
+// handler 只按名称选择策略，新增策略不需要修改 handler
+type DeductStrategy interface {
+	// Name 策略名称，作为 /api/mode/switch 的参数
+	Name() string
+	// Description 策略说明，在 /api/mode/status 中展示
+	Description() string
+	// Deduct 执行一次扣款；req.Credit 为 true 时执行入账，并发控制方式与扣款相同
+	Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error)
+}
+
+// StrategyInfo 策略的展示信息
+type StrategyInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// StrategyRegistry 策略注册表
+// 按名称管理所有可用的扣款策略，并保留注册顺序便于前端稳定展示
+type StrategyRegistry struct {
+	mu         sync.RWMutex
+	strategies map[string]DeductStrategy
+	order      []string
+}
+
+// NewStrategyRegistry 创建空的策略注册表
+func NewStrategyRegistry() *StrategyRegistry {
+	return &StrategyRegistry{
+		strategies: make(map[string]DeductStrategy),
+	}
+}
+
+// Register 注册策略，名称重复时返回错误
+func (r *StrategyRegistry) Register(strategy DeductStrategy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := strategy.Name()
+	if _, exists := r.strategies[name]; exists {
+		return fmt.Errorf("strategy %q already registered", name)
+	}
+
+	r.strategies[name] = strategy
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Get 按名称获取策略
+func (r *StrategyRegistry) Get(name string) (DeductStrategy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	strategy, ok := r.strategies[name]
+	return strategy, ok
+}
+
+// List 按注册顺序列出所有策略
+func (r *StrategyRegistry) List() []StrategyInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]StrategyInfo, 0, len(r.order))
+	for _, name := range r.order {
+		strategy := r.strategies[name]
+		infos = append(infos, StrategyInfo{
+			Name:        strategy.Name(),
+			Description: strategy.Description(),
+		})
+	}
+	return infos
+}
+
+// registerBuiltinStrategies 注册内置策略
+func registerBuiltinStrategies(s *AccountService) {
+	builtins := []DeductStrategy{
+		&unlockedStrategy{svc: s},
+		&mutexStrategy{svc: s},
+		&optimisticStrategy{svc: s},
+		&pessimisticStrategy{svc: s},
+		&atomicStrategy{svc: s},
+		&redisStrategy{svc: s},
+		&advisoryStrategy{svc: s},
+		&actorStrategy{svc: s},
+		&batchStrategy{svc: s},
+		&serializableStrategy{svc: s},
+	}
+	for _, strategy := range builtins {
+		if err := s.strategies.Register(strategy); err != nil {
+			panic(err) // 内置策略名称冲突属于编程错误
+		}
+	}
+}
+
+// unlockedStrategy 不加锁策略，对应 DeductBalance
+type unlockedStrategy struct {
+	svc *AccountService
+}
+
+func (st *unlockedStrategy) Name() string { return StrategyUnlocked }
+
+func (st *unlockedStrategy) Description() string {
+	return "不加锁：读取-计算-写入之间没有任何保护，并发时会出现 Lost Update"
+}
+
+func (st *unlockedStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalance(ctx, req, requestID)
+}
+
+// mutexStrategy 进程内互斥锁策略，对应 DeductBalanceWithLock
+type mutexStrategy struct {
+	svc *AccountService
+}
+
+func (st *mutexStrategy) Name() string { return StrategyMutex }
+
+func (st *mutexStrategy) Description() string {
+	return "进程内互斥锁：单实例内串行化扣款，多实例部署时失效"
+}
+
+func (st *mutexStrategy) Deduct(ctx context.Context, req *DeductRequest, requestID string) (*DeductResponse, error) {
+	return st.svc.DeductBalanceWithLock(ctx, req, requestID)
+}
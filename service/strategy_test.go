@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+func TestMain(m *testing.M) {
+	// 各策略每一步都打日志，测试时只看断言结果
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestService 创建基于内存存储的账户服务，redis 策略使用进程内 Redis 替身
+func newTestService(t *testing.T) (*AccountService, *repository.MemoryAccountRepository) {
+	t.Helper()
+	repo := repository.NewMemoryAccountRepository(repository.MemoryOptions{})
+	svc := NewAccountService(repo)
+
+	_, locker, _ := newTestLockers(t, RedisLockOptions{
+		TTL:            time.Second,
+		AcquireTimeout: 5 * time.Second,
+		RetryInterval:  time.Millisecond,
+	})
+	svc.UseRedisLocker(locker)
+	return svc, repo
+}
+
+// useTestConfig 临时替换全局配置，测试结束后恢复
+func useTestConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+// createTestAccount 创建账户，失败时终止测试
+func createTestAccount(t *testing.T, svc *AccountService, userID, balance int64) {
+	t.Helper()
+	if _, err := svc.CreateAccount(context.Background(), userID, balance); err != nil {
+		t.Fatalf("create account %d: %v", userID, err)
+	}
+}
+
+// deductConcurrently 用指定策略对同一账户并发扣款 n 次，返回成功次数和失败的错误
+func deductConcurrently(t *testing.T, svc *AccountService, strategy string, userID int64, n int, amount int64) (int, []error) {
+	t.Helper()
+	st, ok := svc.Strategies().Get(strategy)
+	if !ok {
+		t.Fatalf("strategy %q not registered", strategy)
+	}
+
+	var (
+		mu       sync.Mutex
+		accepted int
+		errs     []error
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := &DeductRequest{UserID: userID, Amount: amount}
+			_, err := svc.Deduct(context.Background(), st, req, fmt.Sprintf("%s-%d", strategy, i))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			accepted++
+		}(i)
+	}
+	wg.Wait()
+	return accepted, errs
+}
+
+// balanceOf 读取账户余额，失败时终止测试
+func balanceOf(t *testing.T, svc *AccountService, userID int64) int64 {
+	t.Helper()
+	balance, err := svc.GetBalance(userID)
+	if err != nil {
+		t.Fatalf("get balance %d: %v", userID, err)
+	}
+	return balance
+}
+
+// countLedger 统计账户指定类型的流水条数
+func countLedger(t *testing.T, repo repository.AccountRepository, userID int64, txnType string) int {
+	t.Helper()
+	txns, err := repo.ListTransactions(context.Background(), userID, 0, 10000)
+	if err != nil {
+		t.Fatalf("list transactions %d: %v", userID, err)
+	}
+	count := 0
+	for _, txn := range txns {
+		if txn.Type == txnType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDeductStrategiesConcurrent(t *testing.T) {
+	// 乐观锁在 20 个请求同时冲突时默认的 5 次尝试不够，放宽后所有请求都应最终成功
+	useTestConfig(t, &config.Config{Strategy: config.StrategyConfig{
+		Optimistic: config.OptimisticConfig{MaxAttempts: 100, BackoffMs: 1, MaxBackoffMs: 20},
+	}})
+
+	const (
+		userID  int64 = 1
+		initial int64 = 100000
+		amount  int64 = 100
+		n             = 20
+	)
+
+	tests := []struct {
+		strategy    string
+		lostUpdates bool // 不加锁时并发扣款互相覆盖
+	}{
+		{strategy: StrategyUnlocked, lostUpdates: true},
+		{strategy: StrategyMutex},
+		{strategy: StrategyOptimistic},
+		{strategy: StrategyPessimistic},
+		{strategy: StrategyAtomic},
+		{strategy: StrategyRedis},
+		{strategy: StrategyAdvisory},
+		{strategy: StrategyActor},
+		{strategy: StrategyBatch},
+		{strategy: StrategySerializable},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.strategy] = true
+	}
+	svc, _ := newTestService(t)
+	for _, info := range svc.Strategies().List() {
+		if !covered[info.Name] {
+			t.Errorf("strategy %q has no test case", info.Name)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			svc, repo := newTestService(t)
+			createTestAccount(t, svc, userID, initial)
+
+			accepted, errs := deductConcurrently(t, svc, tt.strategy, userID, n, amount)
+			if len(errs) > 0 {
+				t.Fatalf("%d of %d deductions failed, first error: %v", len(errs), n, errs[0])
+			}
+
+			balance := balanceOf(t, svc, userID)
+			ledger := countLedger(t, repo, userID, model.TransactionTypeDeduct)
+			if ledger != accepted {
+				t.Errorf("ledger rows = %d, want %d (one per accepted request)", ledger, accepted)
+			}
+
+			want := initial - int64(accepted)*amount
+			if tt.lostUpdates {
+				if balance == want {
+					t.Errorf("balance = %d, expected lost updates to leave it above %d", balance, want)
+				}
+				return
+			}
+			if balance != want {
+				t.Errorf("balance = %d, want %d", balance, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,624 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zero-balance-loss/model"
+	"zero-balance-loss/repository"
+)
+
+var (
+	// ErrSameAccount 转出和转入是同一个账户
+	ErrSameAccount = errors.New("cannot transfer to the same account")
+	// ErrTransferUnsupported 指定的策略不支持转账
+	ErrTransferUnsupported = errors.New("strategy does not support transfer")
+)
+
+// errTransferCASFailed 乐观锁转账中某个账户 CAS 失败，用于回滚整笔转账后重试
+var errTransferCASFailed = errors.New("transfer compare-and-swap failed")
+
+// TransferRequest 转账请求
+type TransferRequest struct {
+	FromUserID int64  `json:"from_user_id" binding:"required"`
+	ToUserID   int64  `json:"to_user_id" binding:"required"`
+	Amount     int64  `json:"amount" binding:"required"` // 单位：分
+	Strategy   string `json:"strategy"`                  // 并发控制策略，为空时使用当前扣款策略
+}
+
+// TransferResponse 转账响应
+type TransferResponse struct {
+	RequestID      string   `json:"request_id"`
+	FromUserID     int64    `json:"from_user_id"`
+	ToUserID       int64    `json:"to_user_id"`
+	Amount         int64    `json:"amount"`
+	FromOldBalance int64    `json:"from_old_balance"` // 转出账户转账前余额（分）
+	FromBalance    int64    `json:"from_balance"`     // 转出账户转账后余额（分）
+	ToOldBalance   int64    `json:"to_old_balance"`   // 转入账户转账前余额（分）
+	ToBalance      int64    `json:"to_balance"`       // 转入账户转账后余额（分）
+	Strategy       string   `json:"strategy"`
+	Retries        int      `json:"retries"`
+	ErrorClass     string   `json:"error_class,omitempty"`
+	Timeline       Timeline `json:"timeline"`
+}
+
+// Transfer 使用指定策略在两个账户之间转账，成功后两边都计入理论余额
+// 所有加锁的策略都按 user_id 从小到大的顺序加锁，A->B 和 B->A 并发时不会互相等待形成死锁；
+// unlocked 故意不加锁，并发时会凭空多出或少掉钱，可以从余额总和守恒检查中看到
+func (s *AccountService) Transfer(ctx context.Context, strategy string, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if req.FromUserID == req.ToUserID {
+		return nil, ErrSameAccount
+	}
+
+	var resp *TransferResponse
+	var err error
+	switch strategy {
+	case StrategyUnlocked:
+		resp, err = s.transferUnlocked(ctx, req, requestID)
+	case StrategyMutex:
+		resp, err = s.transferWithLock(ctx, req, requestID)
+	case StrategyPessimistic:
+		resp, err = s.transferForUpdate(ctx, req, requestID)
+	case StrategyOptimistic:
+		resp, err = s.transferOptimistic(ctx, req, requestID)
+	case StrategyAtomic:
+		resp, err = s.transferAtomic(ctx, req, requestID)
+	case StrategyAdvisory:
+		resp, err = s.transferWithAdvisoryLock(ctx, req, requestID)
+	case StrategyRedis:
+		resp, err = s.transferWithRedisLock(ctx, req, requestID)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrTransferUnsupported, strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp.RequestID = requestID
+	resp.FromUserID = req.FromUserID
+	resp.ToUserID = req.ToUserID
+	resp.Amount = req.Amount
+	resp.Strategy = strategy
+	s.expected.RecordChange(req.FromUserID, resp.FromOldBalance, -req.Amount)
+	s.expected.RecordChange(req.ToUserID, resp.ToOldBalance, req.Amount)
+	return resp, nil
+}
+
+// lockOrder 按 user_id 从小到大返回两个账户，所有转账都按这个顺序加锁
+func lockOrder(a, b int64) (int64, int64) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// transferUnlocked 转账（不加锁版本，演示用，不安全）
+// 转出和转入各自独立地"读取-计算-写入"，两步之间没有锁也没有事务：
+// 并发时任一步都可能覆盖其他请求的写入，转出被覆盖时钱凭空多出，转入被覆盖时钱凭空消失
+func (s *AccountService) transferUnlocked(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	var timeline Timeline
+	resp := &TransferResponse{}
+
+	// 转出：违反账户策略时直接返回，转入尚未发生
+	timeline.ReadStart = time.Now().UnixNano()
+	from, err := s.getAccount(ctx, req.FromUserID)
+	if err != nil {
+		return nil, err
+	}
+	timeline.ReadEnd = time.Now().UnixNano()
+	if from.Closed() {
+		return nil, ErrAccountClosed
+	}
+	if err := checkDebit(ctx, s.repo, from, req.Amount); err != nil {
+		return nil, err
+	}
+	// 转入账户的状态也在转出前确认，否则转出写入后才发现转入账户已关闭；并发关闭时仍可能发生，这正是不加锁的代价
+	target, err := s.getAccount(ctx, req.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	if target.Closed() {
+		return nil, ErrAccountClosed
+	}
+	log.Printf("[%s] 💸 [TRANSFER] Step 1: 转出账户 %d 余额=%d分", requestID, from.UserID, from.Balance)
+
+	timeline.ComputeStart = time.Now().UnixNano()
+	time.Sleep(10 * time.Millisecond)
+	resp.FromOldBalance, resp.FromBalance = from.Balance, from.Balance-req.Amount
+	timeline.ComputeEnd = time.Now().UnixNano()
+
+	timeline.WriteStart = time.Now().UnixNano()
+	if err := s.repo.UpdateBalance(ctx, req.FromUserID, resp.FromBalance); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	timeline.WriteEnd = time.Now().UnixNano()
+	if err := s.repo.AppendTransaction(ctx, newTransferTransaction(req.FromUserID, model.TransactionTypeTransferOut, req.Amount, requestID, StrategyUnlocked, resp.FromOldBalance, resp.FromBalance, &timeline)); err != nil {
+		return nil, fmt.Errorf("failed to append transaction: %w", err)
+	}
+
+	// 转入：重新读取转入账户，同样是一次不加锁的读改写
+	to, err := s.getAccount(ctx, req.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	if to.Closed() {
+		return nil, ErrAccountClosed
+	}
+	log.Printf("[%s] 💸 [TRANSFER] Step 2: 转入账户 %d 余额=%d分", requestID, to.UserID, to.Balance)
+	time.Sleep(10 * time.Millisecond)
+	resp.ToOldBalance, resp.ToBalance = to.Balance, to.Balance+req.Amount
+	if err := s.repo.UpdateBalance(ctx, req.ToUserID, resp.ToBalance); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	if err := s.repo.AppendTransaction(ctx, newTransferTransaction(req.ToUserID, model.TransactionTypeTransferIn, req.Amount, requestID, StrategyUnlocked, resp.ToOldBalance, resp.ToBalance, &timeline)); err != nil {
+		return nil, fmt.Errorf("failed to append transaction: %w", err)
+	}
+	log.Printf("[%s] 💸 [TRANSFER] Step 3: 转账完成，转出余额=%d分，转入余额=%d分", requestID, resp.FromBalance, resp.ToBalance)
+
+	resp.Timeline = timeline
+	return resp, nil
+}
+
+// transferWithLock 转账（进程内互斥锁版本），按顺序锁住两个账户
+func (s *AccountService) transferWithLock(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	first, second := lockOrder(req.FromUserID, req.ToUserID)
+
+	lockWaitStart := time.Now().UnixNano()
+	unlockFirst, _ := s.accountLocks.Lock(first)
+	defer unlockFirst()
+	unlockSecond, _ := s.accountLocks.Lock(second)
+	defer unlockSecond()
+	lockWaitEnd := time.Now().UnixNano()
+
+	resp, err := s.transferInCriticalSection(ctx, req, requestID, StrategyMutex, "🔒 [TRANSFER]", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Timeline.LockWaitStart = lockWaitStart
+	resp.Timeline.LockWaitEnd = lockWaitEnd
+	return resp, nil
+}
+
+// transferWithAdvisoryLock 转账（数据库命名锁版本），按顺序获取两个账户的命名锁
+func (s *AccountService) transferWithAdvisoryLock(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	settings := advisoryLockSettings()
+	timeout := time.Duration(settings.TimeoutSec) * time.Second
+	first, second := lockOrder(req.FromUserID, req.ToUserID)
+
+	var resp *TransferResponse
+	lockWaitStart := time.Now().UnixNano()
+	err := s.repo.WithAdvisoryLock(ctx, advisoryLockName(first), timeout, func() error {
+		return s.repo.WithAdvisoryLock(ctx, advisoryLockName(second), timeout, func() error {
+			lockWaitEnd := time.Now().UnixNano()
+
+			var err error
+			resp, err = s.transferInCriticalSection(ctx, req, requestID, StrategyAdvisory, "🗝️ [TRANSFER]", nil)
+			if err != nil {
+				return err
+			}
+			resp.Timeline.LockWaitStart = lockWaitStart
+			resp.Timeline.LockWaitEnd = lockWaitEnd
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// transferWithRedisLock 转账（Redis 分布式锁版本），按顺序获取两个账户的锁，写入前确认两个租约都有效
+func (s *AccountService) transferWithRedisLock(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	locker, err := s.getRedisLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := redisLockSettings().KeyPrefix
+	first, second := lockOrder(req.FromUserID, req.ToUserID)
+
+	lockWaitStart := time.Now().UnixNano()
+	var locks []*RedisLock
+	defer func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			if err := locks[i].Release(context.Background()); err != nil {
+				log.Printf("[%s] 🌐 [TRANSFER] 释放锁失败: %v", requestID, err)
+			}
+		}
+	}()
+	for _, userID := range []int64{first, second} {
+		lock, err := locker.Acquire(ctx, fmt.Sprintf("%s%d", prefix, userID))
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	lockWaitEnd := time.Now().UnixNano()
+
+	resp, err := s.transferInCriticalSection(ctx, req, requestID, StrategyRedis, "🌐 [TRANSFER]", func() error {
+		for _, lock := range locks {
+			if lock.Lost() {
+				return ErrLockLost
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Timeline.LockWaitStart = lockWaitStart
+	resp.Timeline.LockWaitEnd = lockWaitEnd
+	return resp, nil
+}
+
+// transferInCriticalSection 在调用方已持有两个账户的锁的前提下执行"读取-计算-写入"
+// 两个账户的余额写入和两条流水在同一事务内提交；读取在事务外，正确性依赖调用方的锁
+func (s *AccountService) transferInCriticalSection(ctx context.Context, req *TransferRequest, requestID, strategy, tag string, beforeWrite func() error) (*TransferResponse, error) {
+	var timeline Timeline
+
+	// 步骤1: 读取两个账户的余额
+	timeline.ReadStart = time.Now().UnixNano()
+	from, err := s.getAccount(ctx, req.FromUserID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getAccount(ctx, req.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	timeline.ReadEnd = time.Now().UnixNano()
+	log.Printf("[%s] %s Step 1: 转出账户 %d 余额=%d分，转入账户 %d 余额=%d分", requestID, tag, from.UserID, from.Balance, to.UserID, to.Balance)
+
+	// 步骤2: 两个账户都未关闭，并按转出账户的策略检查余额和限额
+	if from.Closed() || to.Closed() {
+		return nil, ErrAccountClosed
+	}
+	if err := checkDebit(ctx, s.repo, from, req.Amount); err != nil {
+		return nil, err
+	}
+
+	// 步骤3: 计算阶段（与扣款保持相同的业务延迟，方便对比）
+	timeline.ComputeStart = time.Now().UnixNano()
+	time.Sleep(10 * time.Millisecond)
+	fromBalance := from.Balance - req.Amount
+	toBalance := to.Balance + req.Amount
+	timeline.ComputeEnd = time.Now().UnixNano()
+
+	if beforeWrite != nil {
+		if err := beforeWrite(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 步骤4: 写入两个账户并追加流水
+	err = s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		timeline.WriteStart = time.Now().UnixNano()
+		first, _ := lockOrder(req.FromUserID, req.ToUserID)
+		writes := []struct {
+			userID  int64
+			balance int64
+		}{{req.FromUserID, fromBalance}, {req.ToUserID, toBalance}}
+		if first != req.FromUserID {
+			writes[0], writes[1] = writes[1], writes[0]
+		}
+		// 读取在事务外，写入前按加锁顺序再确认一次账户未关闭
+		for _, w := range writes {
+			if err := ensureActive(ctx, tx, w.userID); err != nil {
+				return err
+			}
+		}
+		for _, w := range writes {
+			if err := tx.UpdateBalance(ctx, w.userID, w.balance); err != nil {
+				return fmt.Errorf("failed to update balance: %w", err)
+			}
+		}
+		timeline.WriteEnd = time.Now().UnixNano()
+		return appendTransferTransactions(ctx, tx, req, requestID, strategy, from.Balance, fromBalance, to.Balance, toBalance, &timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] %s Step 4: 转账成功，转出账户余额=%d分，转入账户余额=%d分", requestID, tag, fromBalance, toBalance)
+
+	return &TransferResponse{
+		FromOldBalance: from.Balance,
+		FromBalance:    fromBalance,
+		ToOldBalance:   to.Balance,
+		ToBalance:      toBalance,
+		Timeline:       timeline,
+	}, nil
+}
+
+// transferForUpdate 转账（悲观锁版本）
+// 在一个事务内按 user_id 顺序 SELECT ... FOR UPDATE 锁住两行；
+// 如果按"先转出后转入"加锁，A->B 和 B->A 并发时各持有一行、等待另一行，就会形成死锁
+func (s *AccountService) transferForUpdate(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	settings := pessimisticSettings()
+	isolation, err := parseIsolationLevel(settings.IsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeline Timeline
+	resp := &TransferResponse{}
+	txOpts := &repository.TxOptions{
+		Isolation:       isolation,
+		LockWaitTimeout: time.Duration(settings.LockWaitTimeoutSec) * time.Second,
+	}
+	err = s.repo.Transaction(ctx, txOpts, func(tx repository.AccountRepository) error {
+		// 步骤1: 按顺序加锁读取两个账户
+		timeline.LockWaitStart = time.Now().UnixNano()
+		first, second := lockOrder(req.FromUserID, req.ToUserID)
+		accounts := make(map[int64]*model.Account, 2)
+		for _, userID := range []int64{first, second} {
+			account, err := tx.GetAccountForUpdate(ctx, userID)
+			if err != nil {
+				return fmt.Errorf("failed to get account: %w", err)
+			}
+			accounts[userID] = account
+		}
+		timeline.LockWaitEnd = time.Now().UnixNano()
+		timeline.ReadStart = timeline.LockWaitStart
+		timeline.ReadEnd = timeline.LockWaitEnd
+
+		from, to := accounts[req.FromUserID], accounts[req.ToUserID]
+		log.Printf("[%s] 🔐 [TRANSFER] 锁定账户 %d、%d，转出余额=%d分，转入余额=%d分", requestID, first, second, from.Balance, to.Balance)
+
+		// 步骤2: 两个账户都未关闭，并按转出账户的策略检查余额和限额
+		if from.Closed() || to.Closed() {
+			return ErrAccountClosed
+		}
+		if err := checkDebit(ctx, tx, from, req.Amount); err != nil {
+			return err
+		}
+
+		// 步骤3: 计算阶段
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		resp.FromOldBalance, resp.FromBalance = from.Balance, from.Balance-req.Amount
+		resp.ToOldBalance, resp.ToBalance = to.Balance, to.Balance+req.Amount
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		// 步骤4: 行锁已持有，写入顺序不影响正确性
+		timeline.WriteStart = time.Now().UnixNano()
+		if err := tx.UpdateBalance(ctx, req.FromUserID, resp.FromBalance); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		if err := tx.UpdateBalance(ctx, req.ToUserID, resp.ToBalance); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		timeline.WriteEnd = time.Now().UnixNano()
+		return appendTransferTransactions(ctx, tx, req, requestID, StrategyPessimistic, resp.FromOldBalance, resp.FromBalance, resp.ToOldBalance, resp.ToBalance, &timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Timeline = timeline
+	return resp, nil
+}
+
+// transferOptimistic 转账（乐观锁版本）
+// 读取两个账户的 version，在一个事务内依次 CAS；任一 CAS 失败就回滚整笔转账，退避后重新读取重试。
+// CAS 只在写入那一刻短暂持有行锁，仍按 user_id 顺序写入，避免两个事务交叉等待
+func (s *AccountService) transferOptimistic(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	settings := optimisticSettings()
+	first, _ := lockOrder(req.FromUserID, req.ToUserID)
+
+	for attempt := 1; attempt <= settings.MaxAttempts; attempt++ {
+		var timeline Timeline
+
+		// 步骤1: 读取两个账户的余额和版本号
+		timeline.ReadStart = time.Now().UnixNano()
+		from, err := s.getAccount(ctx, req.FromUserID)
+		if err != nil {
+			return nil, err
+		}
+		to, err := s.getAccount(ctx, req.ToUserID)
+		if err != nil {
+			return nil, err
+		}
+		timeline.ReadEnd = time.Now().UnixNano()
+
+		// 步骤2: 两个账户都未关闭，并按转出账户的策略检查余额和限额；
+		// 累计上限在 CAS 成功后于同一事务内重新汇总
+		if from.Closed() || to.Closed() {
+			return nil, ErrAccountClosed
+		}
+		if err := checkDebit(ctx, s.repo, from, req.Amount); err != nil {
+			return nil, err
+		}
+
+		// 步骤3: 计算阶段
+		timeline.ComputeStart = time.Now().UnixNano()
+		time.Sleep(10 * time.Millisecond)
+		fromBalance := from.Balance - req.Amount
+		toBalance := to.Balance + req.Amount
+		timeline.ComputeEnd = time.Now().UnixNano()
+
+		// 步骤4: 两个 CAS 在同一事务内，任一失败整笔回滚
+		cas := []struct {
+			account *model.Account
+			balance int64
+		}{{from, fromBalance}, {to, toBalance}}
+		if first != req.FromUserID {
+			cas[0], cas[1] = cas[1], cas[0]
+		}
+		timeline.WriteStart = time.Now().UnixNano()
+		err = s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+			for _, c := range cas {
+				swapped, err := tx.CompareAndSwapBalance(ctx, c.account.UserID, c.account.Version, c.balance)
+				if err != nil {
+					return fmt.Errorf("failed to update balance: %w", err)
+				}
+				if !swapped {
+					return errTransferCASFailed
+				}
+			}
+			if err := recheckDailyLimit(ctx, tx, from, req.Amount); err != nil {
+				return err
+			}
+			timeline.WriteEnd = time.Now().UnixNano()
+			return appendTransferTransactions(ctx, tx, req, requestID, StrategyOptimistic, from.Balance, fromBalance, to.Balance, toBalance, &timeline)
+		})
+		if err == nil {
+			log.Printf("[%s] 🔁 [TRANSFER #%d] CAS 成功，转出余额=%d分，转入余额=%d分", requestID, attempt, fromBalance, toBalance)
+			resp := &TransferResponse{
+				FromOldBalance: from.Balance,
+				FromBalance:    fromBalance,
+				ToOldBalance:   to.Balance,
+				ToBalance:      toBalance,
+				Retries:        attempt - 1,
+				Timeline:       timeline,
+			}
+			if attempt > 1 {
+				resp.ErrorClass = ErrorClassVersionConflict
+			}
+			return resp, nil
+		}
+		if !errors.Is(err, errTransferCASFailed) {
+			return nil, err
+		}
+
+		log.Printf("[%s] 🔁 [TRANSFER #%d] CAS 失败，账户已被其他请求修改", requestID, attempt)
+		if attempt == settings.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(optimisticBackoff(settings, attempt)):
+		}
+	}
+
+	return nil, &RetryError{
+		Retries: settings.MaxAttempts - 1,
+		Class:   ErrorClassVersionConflict,
+		Err:     ErrOptimisticConflict,
+	}
+}
+
+// transferAtomic 转账（原子条件更新版本）
+// 转出用 UPDATE ... SET balance = balance - ? WHERE <账户策略条件>，转入用 UPDATE ... SET balance = balance + ?，
+// 两条语句在同一事务内按 user_id 顺序执行；转出违反策略或余额不足时整笔回滚
+func (s *AccountService) transferAtomic(ctx context.Context, req *TransferRequest, requestID string) (*TransferResponse, error) {
+	var timeline Timeline
+	resp := &TransferResponse{}
+
+	err := s.repo.Transaction(ctx, nil, func(tx repository.AccountRepository) error {
+		start := time.Now().UnixNano()
+		debit := func() error {
+			applied, err := tx.DecrementBalanceWithinPolicy(ctx, req.FromUserID, req.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to update balance: %w", err)
+			}
+			// 回读：区分账户不存在和违反策略，累计上限也要用到账户策略
+			from, err := tx.GetAccount(ctx, req.FromUserID)
+			if err != nil {
+				return fmt.Errorf("failed to get account: %w", err)
+			}
+			if from.Closed() {
+				return ErrAccountClosed
+			}
+			if !applied {
+				if err := checkPolicy(from.Policy, from.Available(), req.Amount); err != nil {
+					return err
+				}
+				return ErrInsufficientBalance
+			}
+			// 累计上限在持有行锁时汇总，转出流水尚未追加，超限则整笔回滚
+			spent, err := dailySpent(ctx, tx, req.FromUserID, from.Policy)
+			if err != nil {
+				return err
+			}
+			return checkDailyLimit(from.Policy, spent, req.Amount)
+		}
+		credit := func() error {
+			if err := tx.IncrementBalance(ctx, req.ToUserID, req.Amount); err != nil {
+				return fmt.Errorf("failed to update balance: %w", err)
+			}
+			// 入账是无条件 UPDATE，行锁已持有，回读到已关闭则整笔回滚
+			to, err := tx.GetAccount(ctx, req.ToUserID)
+			if err != nil {
+				return fmt.Errorf("failed to get account: %w", err)
+			}
+			if to.Closed() {
+				return ErrAccountClosed
+			}
+			return nil
+		}
+		steps := []func() error{debit, credit}
+		if first, _ := lockOrder(req.FromUserID, req.ToUserID); first != req.FromUserID {
+			steps[0], steps[1] = steps[1], steps[0]
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		end := time.Now().UnixNano()
+		timeline.ReadStart, timeline.ReadEnd = start, end
+		timeline.ComputeStart, timeline.ComputeEnd = start, end
+		timeline.WriteStart, timeline.WriteEnd = start, end
+
+		// 回读新余额，行锁持有到事务提交，回读值即本次写入结果
+		from, err := tx.GetAccount(ctx, req.FromUserID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		to, err := tx.GetAccount(ctx, req.ToUserID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		resp.FromOldBalance, resp.FromBalance = from.Balance+req.Amount, from.Balance
+		resp.ToOldBalance, resp.ToBalance = to.Balance-req.Amount, to.Balance
+		return appendTransferTransactions(ctx, tx, req, requestID, StrategyAtomic, resp.FromOldBalance, resp.FromBalance, resp.ToOldBalance, resp.ToBalance, &timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[%s] ⚛️ [TRANSFER] 转账成功，转出余额=%d分，转入余额=%d分", requestID, resp.FromBalance, resp.ToBalance)
+	resp.Timeline = timeline
+	return resp, nil
+}
+
+// appendTransferTransactions 追加转出和转入两条流水，两条流水使用同一个请求ID
+func appendTransferTransactions(ctx context.Context, repo repository.AccountRepository, req *TransferRequest, requestID, strategy string, fromOld, fromNew, toOld, toNew int64, timeline *Timeline) error {
+	entries := []*model.Transaction{
+		newTransferTransaction(req.FromUserID, model.TransactionTypeTransferOut, req.Amount, requestID, strategy, fromOld, fromNew, timeline),
+		newTransferTransaction(req.ToUserID, model.TransactionTypeTransferIn, req.Amount, requestID, strategy, toOld, toNew, timeline),
+	}
+	for _, txn := range entries {
+		if err := repo.AppendTransaction(ctx, txn); err != nil {
+			return fmt.Errorf("failed to append transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// newTransferTransaction 构造一条转账流水
+func newTransferTransaction(userID int64, txnType string, amount int64, requestID, strategy string, oldBalance, newBalance int64, timeline *Timeline) *model.Transaction {
+	return &model.Transaction{
+		RequestID:     requestID,
+		UserID:        userID,
+		Type:          txnType,
+		Amount:        amount,
+		BalanceBefore: oldBalance,
+		BalanceAfter:  newBalance,
+		Strategy:      strategy,
+		ReadStart:     timeline.ReadStart,
+		ReadEnd:       timeline.ReadEnd,
+		ComputeStart:  timeline.ComputeStart,
+		ComputeEnd:    timeline.ComputeEnd,
+		WriteStart:    timeline.WriteStart,
+		WriteEnd:      timeline.WriteEnd,
+	}
+}
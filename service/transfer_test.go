@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"zero-balance-loss/config"
+	"zero-balance-loss/model"
+)
+
+func TestTransferConcurrentOppositeDirections(t *testing.T) {
+	useTestConfig(t, &config.Config{Strategy: config.StrategyConfig{
+		Optimistic: config.OptimisticConfig{MaxAttempts: 100, BackoffMs: 1, MaxBackoffMs: 20},
+	}})
+
+	const (
+		userA   int64 = 1
+		userB   int64 = 2
+		initial int64 = 100000
+		amount  int64 = 100
+		n             = 20 // 每个方向的转账次数
+	)
+
+	// unlocked 故意不加锁，不保证守恒，不在这里测试
+	strategies := []string{
+		StrategyMutex,
+		StrategyPessimistic,
+		StrategyOptimistic,
+		StrategyAtomic,
+		StrategyAdvisory,
+		StrategyRedis,
+	}
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			svc, repo := newTestService(t)
+			createTestAccount(t, svc, userA, initial)
+			createTestAccount(t, svc, userB, initial)
+
+			var (
+				mu   sync.Mutex
+				errs []error
+				wg   sync.WaitGroup
+			)
+			for i := 0; i < 2*n; i++ {
+				from, to := userA, userB
+				if i%2 == 1 {
+					from, to = userB, userA
+				}
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					req := &TransferRequest{FromUserID: from, ToUserID: to, Amount: amount}
+					if _, err := svc.Transfer(context.Background(), strategy, req, fmt.Sprintf("%s-%d", strategy, i)); err != nil {
+						mu.Lock()
+						errs = append(errs, err)
+						mu.Unlock()
+					}
+				}(i)
+			}
+
+			// A->B 和 B->A 交错加锁时会死锁，锁等待超时（默认 5 秒）之前必须全部完成
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("transfers did not finish within the lock wait timeout (deadlock?)")
+			}
+			if len(errs) > 0 {
+				t.Fatalf("%d of %d transfers failed, first error: %v", len(errs), 2*n, errs[0])
+			}
+
+			balanceA, balanceB := balanceOf(t, svc, userA), balanceOf(t, svc, userB)
+			if total := balanceA + balanceB; total != 2*initial {
+				t.Errorf("total balance = %d (A=%d, B=%d), want %d", total, balanceA, balanceB, 2*initial)
+			}
+			// 两个方向次数相同，各自回到初始余额
+			if balanceA != initial || balanceB != initial {
+				t.Errorf("balances = (%d, %d), want (%d, %d)", balanceA, balanceB, initial, initial)
+			}
+			for _, userID := range []int64{userA, userB} {
+				if out := countLedger(t, repo, userID, model.TransactionTypeTransferOut); out != n {
+					t.Errorf("user %d transfer_out rows = %d, want %d", userID, out, n)
+				}
+				if in := countLedger(t, repo, userID, model.TransactionTypeTransferIn); in != n {
+					t.Errorf("user %d transfer_in rows = %d, want %d", userID, in, n)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferUnsupportedStrategies(t *testing.T) {
+	svc, _ := newTestService(t)
+	createTestAccount(t, svc, 1, 1000)
+	createTestAccount(t, svc, 2, 1000)
+
+	for _, strategy := range []string{StrategyActor, StrategyBatch, StrategySerializable} {
+		req := &TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100}
+		if _, err := svc.Transfer(context.Background(), strategy, req, strategy); !errors.Is(err, ErrTransferUnsupported) {
+			t.Errorf("%s: err = %v, want ErrTransferUnsupported", strategy, err)
+		}
+	}
+	if balanceOf(t, svc, 1) != 1000 || balanceOf(t, svc, 2) != 1000 {
+		t.Fatal("unsupported transfer changed balances")
+	}
+}